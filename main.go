@@ -21,6 +21,8 @@ func init() {
 	flag.BoolVar(&maze.DebugMode, "debug", false, "turn debug graphics on")
 	flag.IntVar(&maze.WindowWidth, "width", 1920/2, "width of window in pixels")
 	flag.IntVar(&maze.WindowHeight, "height", 1080/2, "height of window in pixels")
+	flag.Int64Var(&maze.Seed, "seed", 0, "seed for building levels (0 for random)")
+	flag.BoolVar(&maze.RewindGhosts, "rewindghosts", false, "undo and redo move the ghosts too")
 }
 
 func main() {
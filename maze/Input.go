@@ -22,10 +22,38 @@ type (
 	}
 )
 
+// dragThreshold is how far (in pixels) the pointer has to move while down before it counts as a drag, not a tap
+const dragThreshold = 8
+
+// PointerKind says what a PointerEvent is about
+type PointerKind int
+
+// the kinds of PointerEvent
+const (
+	PointerPress   PointerKind = iota // the mouse button or a touch has gone down
+	PointerDrag                       // it has moved while down, far enough to be a drag
+	PointerRelease                    // it has come up
+)
+
+// PointerEvent is sent to observers as the left mouse button or a single touch goes down,
+// is dragged, and comes up. A release that wasn't dragged is also sent as a plain
+// image.Point, so observers that only want taps needn't know about PointerEvent.
+type PointerEvent struct {
+	Kind    PointerKind
+	Pt      image.Point // in screen coords
+	Dragged bool        // true if the pointer has been dragged since it went down
+}
+
 // Input records state of mouse and touch, Subject in Observer pattern
 type Input struct {
 	// pressed        map[ebiten.Key]struct{} // an empty and useless type
 	observer sync.Map
+
+	down            bool // the mouse button or a touch is down
+	touch           bool // it's a touch (with ID touchID), not the mouse
+	touchID         ebiten.TouchID
+	pressPt, lastPt image.Point
+	dragged         bool
 }
 
 // NewInput Input object constructor
@@ -58,18 +86,7 @@ func (i *Input) Notify(event interface{}) {
 // Update the state of the Input object
 func (i *Input) Update() {
 
-	if inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft) {
-		x, y := ebiten.CursorPosition()
-		i.Notify(image.Point{X: x, Y: y})
-	} else {
-		ts := inpututil.JustPressedTouchIDs()
-		if len(ts) == 1 { // len() for nil slices is zero
-			if inpututil.IsTouchJustReleased(ts[0]) {
-				x, y := ebiten.TouchPosition(ts[0])
-				i.Notify(image.Point{X: x, Y: y})
-			}
-		}
-	}
+	i.updatePointer()
 
 	for k := ebiten.Key(0); k <= ebiten.KeyMax; k++ {
 		if inpututil.IsKeyJustPressed(k) {
@@ -77,3 +94,56 @@ func (i *Input) Update() {
 		}
 	}
 }
+
+// updatePointer follows the left mouse button, or a single touch, from going down to coming up
+func (i *Input) updatePointer() {
+	if !i.down {
+		var pt image.Point
+		if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+			pt.X, pt.Y = ebiten.CursorPosition()
+			i.touch = false
+		} else if ts := inpututil.JustPressedTouchIDs(); len(ts) == 1 { // len() for nil slices is zero
+			pt.X, pt.Y = ebiten.TouchPosition(ts[0])
+			i.touch, i.touchID = true, ts[0]
+		} else {
+			return
+		}
+		i.down, i.dragged = true, false
+		i.pressPt, i.lastPt = pt, pt
+		i.Notify(PointerEvent{Kind: PointerPress, Pt: pt})
+		return
+	}
+
+	var pt image.Point
+	var released bool
+	if i.touch {
+		if released = inpututil.IsTouchJustReleased(i.touchID); released {
+			// a touch that has gone has no position, so use where it was last
+			pt.X, pt.Y = inpututil.TouchPositionInPreviousTick(i.touchID)
+		} else {
+			pt.X, pt.Y = ebiten.TouchPosition(i.touchID)
+		}
+	} else {
+		pt.X, pt.Y = ebiten.CursorPosition()
+		released = inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft)
+	}
+
+	if pt != i.lastPt {
+		i.lastPt = pt
+		d := pt.Sub(i.pressPt)
+		if d.X*d.X+d.Y*d.Y > dragThreshold*dragThreshold {
+			i.dragged = true
+		}
+		if i.dragged {
+			i.Notify(PointerEvent{Kind: PointerDrag, Pt: pt, Dragged: true})
+		}
+	}
+
+	if released {
+		i.down = false
+		i.Notify(PointerEvent{Kind: PointerRelease, Pt: pt, Dragged: i.dragged})
+		if !i.dragged {
+			i.Notify(pt)
+		}
+	}
+}
@@ -19,26 +19,45 @@ type TextButton struct {
 	origin        image.Point
 	width, height int
 	img           *ebiten.Image
+	disabled      bool // greyed out, and does nothing when tapped
 }
 
 // NewTextButton creates and returns a new TextButton object centered at x,y
 func NewTextButton(str string, w int, h int, btnFont font.Face, actionFn func(), i *Input) *TextButton {
 
 	tb := &TextButton{text: str, width: w, height: h, font: btnFont, action: actionFn}
+	tb.createImage()
 
+	i.Add(tb)
+
+	return tb
+}
+
+func (tb *TextButton) createImage() {
+	w, h := tb.width, tb.height
 	dc := gg.NewContext(w, h)
-	dc.SetRGB(0, 0, 0)
+	if tb.disabled {
+		dc.SetRGB(0.25, 0.25, 0.25)
+	} else {
+		dc.SetRGB(0, 0, 0)
+	}
 	dc.DrawRoundedRectangle(0, 0, float64(w), float64(h), float64(w/20))
 	dc.Fill()
-	dc.SetRGB(1, 1, 1)
+	if tb.disabled {
+		dc.SetRGB(0.5, 0.5, 0.5)
+	} else {
+		dc.SetRGB(1, 1, 1)
+	}
 	dc.SetFontFace(tb.font)
 	dc.DrawStringAnchored(tb.text, float64(w/2), float64(h/2), 0.5, 0.333)
 	dc.Stroke()
 	tb.img = ebiten.NewImageFromImage(dc.Image())
+}
 
-	i.Add(tb)
-
-	return tb
+// Disable greys out the button, and stops it doing anything when tapped
+func (tb *TextButton) Disable() {
+	tb.disabled = true
+	tb.createImage()
 }
 
 // NotifyCallback is called by the Subject (Input) when something interesting happens
@@ -75,7 +94,7 @@ func (tb *TextButton) Rect() (x0 int, y0 int, x1 int, y1 int) {
 
 // Action invokes the action func
 func (tb *TextButton) Action() {
-	if tb.action != nil {
+	if tb.action != nil && !tb.disabled {
 		tb.action()
 	}
 }
@@ -0,0 +1,53 @@
+package maze
+
+import (
+	"oddstream.games/gomaze/metrics"
+)
+
+// gridGraph lets the metrics package see a Grid, with cells numbered in Grid.AllTiles order
+type gridGraph struct {
+	tiles []*Tile
+	index map[*Tile]int
+}
+
+func newGridGraph(g *Grid) gridGraph {
+	gg := gridGraph{index: make(map[*Tile]int, len(g.tiles))}
+	g.AllTiles(func(t *Tile) {
+		gg.index[t] = len(gg.tiles)
+		gg.tiles = append(gg.tiles, t)
+	})
+	return gg
+}
+
+// Len implements metrics.Graph
+func (gg gridGraph) Len() int {
+	return len(gg.tiles)
+}
+
+// Exits implements metrics.Graph
+func (gg gridGraph) Exits(i int) []int {
+	var exits []int
+	t := gg.tiles[i]
+	for _, d := range ALL_DIRECTIONS {
+		if tn := t.neighbour(d); tn != nil && !t.isWall(d) {
+			exits = append(exits, gg.index[tn])
+		}
+	}
+	if t.stairs != nil {
+		exits = append(exits, gg.index[t.stairs])
+	}
+	return exits
+}
+
+// Metrics measures the grid's maze as it is now, with distances measured from the pen
+// (so Unreachable agrees with the tiles visitTiles leaves unvisited)
+func (g *Grid) Metrics() metrics.Report {
+	gg := newGridGraph(g)
+	var pen []int
+	for i, t := range gg.tiles {
+		if t.pen {
+			pen = append(pen, i)
+		}
+	}
+	return metrics.Analyse(gg, pen)
+}
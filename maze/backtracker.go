@@ -0,0 +1,28 @@
+package maze
+
+import (
+	"math/rand"
+)
+
+// RecursiveBacktracker carves a maze with a randomized depth first search,
+// which gives long twisty corridors and few dead ends
+type RecursiveBacktracker struct{}
+
+func (t *Tile) recursiveBacktracker(rng *rand.Rand) {
+	for _, i := range rng.Perm(len(t.edges)) {
+		dir := Direction(i)
+		tn := t.neighbour(dir)
+		if tn != nil && !tn.visited {
+			t.removeWall(dir)
+			tn.visited = true
+			tn.recursiveBacktracker(rng)
+		}
+	}
+}
+
+// Generate implements Generator
+func (RecursiveBacktracker) Generate(g *Grid, rng *rand.Rand) {
+	t := g.tiles[rng.Intn(len(g.tiles))]
+	t.visited = true
+	t.recursiveBacktracker(rng)
+}
@@ -39,10 +39,17 @@ func NewBall(start *Tile, col color.RGBA) *Ball {
 func (b *Ball) StartThrow(to *Tile) {
 	b.dest = to
 	b.srcX, b.srcY = b.tile.position()
-	b.dstX, b.dstY = b.dest.position()
+	b.dstX, b.dstY = b.dest.positionNear(b.srcX, b.srcY)
 	b.lerpstep = 0.025
 }
 
+// Drop the ball on a tile, without throwing it there
+func (b *Ball) Drop(to *Tile) {
+	b.tile = to
+	b.dest = nil
+	b.worldX, b.worldY = b.tile.positionNear(b.worldX, b.worldY)
+}
+
 // String representation of this ball
 func (b *Ball) String() string {
 	return fmt.Sprintf("(%v,%v)", b.tile.X, b.tile.Y)
@@ -0,0 +1,28 @@
+package maze
+
+import (
+	"math/rand"
+)
+
+// BinaryTree carves a maze by knocking down either the north or the east wall
+// of every tile. It is very fast but strongly biased: the north and east edges
+// are always open corridors, and every path trends towards the north-east corner.
+// Square tiles only.
+type BinaryTree struct{}
+
+// Generate implements Generator
+func (BinaryTree) Generate(g *Grid, rng *rand.Rand) {
+	needSquareTiles(g, "BinaryTree")
+
+	for _, t := range g.tiles {
+		var dirs []Direction
+		for _, d := range []Direction{NORTH, EAST} {
+			if t.neighbour(d) != nil {
+				dirs = append(dirs, d)
+			}
+		}
+		if len(dirs) > 0 {
+			t.removeWall(dirs[rng.Intn(len(dirs))])
+		}
+	}
+}
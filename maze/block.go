@@ -0,0 +1,183 @@
+package maze
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/fogleman/gg"
+	"github.com/hajimehoshi/ebiten/v2"
+	"oddstream.games/gomaze/util"
+)
+
+var blockImage *ebiten.Image
+
+func init() {
+	dc := gg.NewContext(TileSize, TileSize)
+	mid := float64(TileSize / 2)
+	third := float64(TileSize / 3)
+	dc.DrawRoundedRectangle(mid-third, mid-third, third*2, third*2, float64(TileSize/12))
+	dc.SetRGB(0.55, 0.45, 0.35)
+	dc.FillPreserve()
+	dc.SetRGBA(0, 0, 0, 0.6)
+	dc.SetLineWidth(float64(TileSize / 20))
+	dc.Stroke()
+	blockImage = ebiten.NewImageFromImage(dc.Image())
+}
+
+// Block sits on a tile, in the way of everything, until a puck pushes it onto the next tile along (like Sokoban)
+type Block struct {
+	tile                   *Tile   // tile we are sitting on
+	dest                   *Tile   // tile we are being pushed onto
+	srcX, srcY, dstX, dstY float64 // positions for lerp
+	lerpstep               float64
+	worldX, worldY         float64
+}
+
+// NewBlock creates a new Block object
+func NewBlock(start *Tile) *Block {
+	b := &Block{tile: start}
+	b.worldX, b.worldY = b.tile.position()
+	return b
+}
+
+// place puts the block straight on a tile, without lerping there
+func (b *Block) place(t *Tile) {
+	b.tile = t
+	b.dest = nil
+	b.worldX, b.worldY = b.tile.position()
+	TheGrid.flow = nil
+}
+
+// blockCost is a CostFunc like terrainCost, except that nothing can go through a block
+func blockCost(from, to *Tile) float64 {
+	if TheGrid.blockOn(to) {
+		return math.Inf(1)
+	}
+	return terrainCost(from, to)
+}
+
+// placeBlocks puts the level's blocks where it says they go; if it doesn't say, it puts
+// lvl.Blocks of them on random reachable tiles, away from the pen and stairs and from anything
+// already there, using no randomness if there are no blocks to place
+func (g *Grid) placeBlocks(lvl Level) {
+	for _, pt := range lvl.BlockAt {
+		t := g.floors[0].findTile(pt.X, pt.Y)
+		if t == nil || t.pen || t.stairs != nil || g.occupied(t) {
+			log.Fatal("can't put a block at ", pt)
+		}
+		g.blocks = append(g.blocks, NewBlock(t))
+	}
+	if len(lvl.BlockAt) > 0 {
+		return
+	}
+	for i := 0; i < lvl.Blocks; i++ {
+		for _, j := range g.rng.Perm(len(g.tiles)) {
+			t := g.tiles[j]
+			if t.visited && !t.pen && t.stairs == nil && t.terrain == PLAIN && !g.occupied(t) {
+				g.blocks = append(g.blocks, NewBlock(t))
+				break
+			}
+		}
+	}
+}
+
+// blockAt returns the block sitting on t, or nil if there isn't one
+func (g *Grid) blockAt(t *Tile) *Block {
+	for _, b := range g.blocks {
+		if b.tile == t {
+			return b
+		}
+	}
+	return nil
+}
+
+// blockOn returns true if any block is on t, or being pushed there
+func (g *Grid) blockOn(t *Tile) bool {
+	for _, b := range g.blocks {
+		if b.tile == t || b.dest == t {
+			return true
+		}
+	}
+	return false
+}
+
+// occupied returns true if anything (puck, block, ghost or meanie) is on t, or on its way there
+func (g *Grid) occupied(t *Tile) bool {
+	if g.puckOn(t) || g.blockOn(t) {
+		return true
+	}
+	for _, gh := range g.ghosts {
+		if gh.tile == t || gh.dest == t {
+			return true
+		}
+	}
+	for _, m := range g.meanies {
+		if m.tile == t || m.dest == t {
+			return true
+		}
+	}
+	return false
+}
+
+// push moves the block on to the next tile along, away from a puck coming from the tile from,
+// returning false if it can't go because of a wall or something in the way.
+// Blocks are never pushed into the pen, or the ghosts might not be able to get home.
+func (b *Block) push(from *Tile) bool {
+	if b.dest != nil {
+		return false
+	}
+	d := whichDirIs(from, b.tile)
+	if d < 0 {
+		return false // blocks can't be pushed up or down stairs
+	}
+	// the way out of the block's tile on the far side from the puck, as ice does it
+	d = b.tile.wallTowards(TheTopology.WallAngle(from, d))
+	if b.tile.isWall(d) {
+		return false
+	}
+	tn := b.tile.neighbour(d)
+	if tn == nil || tn.pen || TheGrid.occupied(tn) {
+		return false
+	}
+	TheGrid.history.record(blockMove{b: b, from: b.tile, to: tn})
+	b.dest = tn
+	b.lerpstep = 0
+	b.srcX, b.srcY = b.tile.position()
+	b.dstX, b.dstY = b.dest.positionNear(b.srcX, b.srcY)
+	// the meanies have to find another way round
+	TheGrid.flow = nil
+	TheGrid.minimapImage = nil
+	return true
+}
+
+// String representation of block
+func (b *Block) String() string {
+	return fmt.Sprintf("(%v,%v)", b.tile.X, b.tile.Y)
+}
+
+// Update the position of the Block, which only moves while being pushed, keeping pace
+// with the puck pushing it; blocks don't slide on ice or ride conveyors, they're too heavy
+func (b *Block) Update() error {
+	if b.dest == nil {
+		return nil
+	}
+	if b.lerpstep >= 1 {
+		b.tile = b.dest
+		b.worldX, b.worldY = b.tile.position()
+		b.dest = nil
+	} else {
+		b.worldX = util.Lerp(b.srcX, b.dstX, b.lerpstep)
+		b.worldY = util.Lerp(b.srcY, b.dstY, b.lerpstep)
+		b.lerpstep += 0.05 * b.tile.terrain.speed()
+	}
+	return nil
+}
+
+// Draw the Block
+func (b *Block) Draw(screen *ebiten.Image) {
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Translate(b.worldX, b.worldY)
+	op.GeoM.Translate(CameraX, CameraY)
+	screen.DrawImage(blockImage, op)
+}
@@ -1,9 +1,12 @@
 package maze
 
 import (
+	"fmt"
 	"image"
 
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"golang.org/x/image/font"
 
 	"github.com/fogleman/gg"
 )
@@ -13,11 +16,19 @@ type Cutscene struct {
 	circleImage     *ebiten.Image
 	circlePos       image.Point
 	startX, finishX int
+	level           int      // the level to play once the cutscene is over, or len(LevelData) for game over
+	speed           int      // how far the circle goes each tick
+	lines           []string // shown in the middle of the screen, if there's a score
 }
 
-// NewCutscene creates and initializes a Cutscene/GameState object
+// NewCutscene creates and initializes a Cutscene/GameState object, leading to the level after the ones completed
 func NewCutscene() *Cutscene {
-	cs := &Cutscene{}
+	return NewLevelCutscene(TheUserData.CompletedLevels)
+}
+
+// NewLevelCutscene creates a Cutscene leading to a level
+func NewLevelCutscene(level int) *Cutscene {
+	cs := &Cutscene{speed: 20, level: level}
 
 	dc := gg.NewContext(200, 200)
 	dc.SetRGB(1, 1, 0)
@@ -29,6 +40,21 @@ func NewCutscene() *Cutscene {
 	return cs
 }
 
+// NewScoreCutscene creates a Cutscene that shows the score for the level just finished,
+// and the best score for it, going slowly enough for them to be read, leading to the next level
+// (or to game over, after the last level)
+func NewScoreCutscene(level, score int, best bool) *Cutscene {
+	cs := NewLevelCutscene(level + 1)
+	cs.speed = 6
+	cs.lines = append(cs.lines, fmt.Sprintf("Level %d score %d", level+1, score))
+	if best {
+		cs.lines = append(cs.lines, "New best!")
+	} else {
+		cs.lines = append(cs.lines, fmt.Sprintf("Best %d", TheUserData.BestScores[level]))
+	}
+	return cs
+}
+
 // Layout implements ebiten.Game's Layout
 func (cs *Cutscene) Layout(outsideWidth, outsideHeight int) (int, int) {
 
@@ -46,11 +72,14 @@ func (cs *Cutscene) Layout(outsideWidth, outsideHeight int) (int, int) {
 // Update updates the current game state.
 func (cs *Cutscene) Update() error {
 
-	cs.circlePos.X += 20
+	cs.circlePos.X += cs.speed
 	if cs.circlePos.X > cs.finishX {
-		lvl := TheUserData.CompletedLevels
-		TheGrid = NewGrid(LevelData[lvl][0], LevelData[lvl][1], LevelData[lvl][2])
-		GSM.Switch(TheGrid)
+		if cs.level >= len(LevelData) {
+			GSM.Switch(NewGameover())
+		} else {
+			TheGrid = NewGrid(cs.level, LevelSeed(cs.level))
+			GSM.Switch(TheGrid)
+		}
 	}
 
 	return nil
@@ -63,4 +92,13 @@ func (cs *Cutscene) Draw(screen *ebiten.Image) {
 	op := &ebiten.DrawImageOptions{}
 	op.GeoM.Translate(float64(cs.circlePos.X), float64(cs.circlePos.Y))
 	screen.DrawImage(cs.circleImage, op)
+
+	for i, str := range cs.lines {
+		bound, _ := font.BoundString(TheAcmeFonts.large, str)
+		w := (bound.Max.X - bound.Min.X).Ceil()
+		h := (bound.Max.Y - bound.Min.Y).Ceil()
+		x := (WindowWidth - w) / 2
+		y := WindowHeight/3 + i*h*2
+		text.Draw(screen, str, TheAcmeFonts.large, x, y, BasicColors["White"])
+	}
 }
@@ -0,0 +1,34 @@
+package maze
+
+// disjointSet is a union-find structure over tiles, used by the
+// Kruskal and RoomsAndMazes generators to track which tiles are already connected
+type disjointSet map[*Tile]*Tile
+
+// find returns the representative tile of the set containing t
+func (ds disjointSet) find(t *Tile) *Tile {
+	root := t
+	for {
+		p, ok := ds[root]
+		if !ok || p == root {
+			break
+		}
+		root = p
+	}
+	// path compression
+	for t != root {
+		p := ds[t]
+		ds[t] = root
+		t = p
+	}
+	return root
+}
+
+// union merges the sets containing a and b, returning false if they were already the same set
+func (ds disjointSet) union(a, b *Tile) bool {
+	ra, rb := ds.find(a), ds.find(b)
+	if ra == rb {
+		return false
+	}
+	ds[rb] = ra
+	return true
+}
@@ -0,0 +1,73 @@
+package maze
+
+import (
+	"math/rand"
+)
+
+// Eller carves a maze one row at a time, only ever remembering which set
+// each tile of the current row belongs to. Tiles in different sets are
+// randomly joined sideways, then every set sends at least one passage down.
+// Square tiles only.
+type Eller struct{}
+
+// Generate implements Generator
+func (Eller) Generate(g *Grid, rng *rand.Rand) {
+	needSquareTiles(g, "Eller")
+
+	set := map[*Tile]int{}
+	nextSet := 1
+
+	for y := 0; y < TilesDown; y++ {
+		lastRow := y == TilesDown-1
+
+		row := make([]*Tile, TilesAcross)
+		for x := range row {
+			row[x] = g.findTile(x, y)
+			if set[row[x]] == 0 {
+				set[row[x]] = nextSet
+				nextSet++
+			}
+		}
+
+		// join adjacent tiles in different sets; the last row must join them all
+		for x := 0; x < TilesAcross-1; x++ {
+			a, b := row[x], row[x+1]
+			if set[a] != set[b] && (lastRow || rng.Intn(2) == 0) {
+				a.removeWall(EAST)
+				from, to := set[b], set[a]
+				for _, t := range row {
+					if set[t] == from {
+						set[t] = to
+					}
+				}
+			}
+		}
+
+		if lastRow {
+			break
+		}
+
+		// group the row's tiles by set, in row order so the result only depends on rng
+		var order []int
+		members := map[int][]*Tile{}
+		for _, t := range row {
+			s := set[t]
+			if _, ok := members[s]; !ok {
+				order = append(order, s)
+			}
+			members[s] = append(members[s], t)
+		}
+
+		// each set must extend down at least once, or it would be cut off
+		for _, s := range order {
+			lst := members[s]
+			rng.Shuffle(len(lst), func(i, j int) { lst[i], lst[j] = lst[j], lst[i] })
+			for i, t := range lst {
+				if i == 0 || rng.Intn(2) == 0 {
+					t.removeWall(SOUTH)
+					set[t.neighbour(SOUTH)] = s
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package maze
+
+import "math"
+
+// flowField is the cost of getting from every tile to the nearest puck, allowing for terrain and going round blocks.
+// It's worked out once for wherever the pucks are, and then every meanie just rolls
+// downhill on it, so lots of meanies cost hardly more than one.
+type flowField struct {
+	targets []*Tile           // the tiles the field leads to
+	dist    map[*Tile]float64 // cost of getting from a tile to the nearest target; tiles that can't get there are missing
+}
+
+// newFlowField works out the cost of getting from every tile to the nearest of targets
+func (g *Grid) newFlowField(targets []*Tile) *flowField {
+	return &flowField{targets: targets, dist: g.DijkstraDistancesToAny(targets, blockCost)}
+}
+
+// flowToPuck returns the flow field leading to the pucks, working it out again
+// if a puck has moved to another tile, or the walls or blocks have changed since last time
+func (g *Grid) flowToPuck() *flowField {
+	moved := g.flow == nil
+	for i, p := range g.pucks {
+		if !moved && g.flow.targets[i] != p.tile {
+			moved = true
+		}
+	}
+	if moved {
+		targets := make([]*Tile, len(g.pucks))
+		for i, p := range g.pucks {
+			targets[i] = p.tile
+		}
+		g.flow = g.newFlowField(targets)
+	}
+	return g.flow
+}
+
+// next returns the neighbour of t that is the cheapest way to the field's nearest target,
+// or nil if t is already at a target or can't get to one
+func (f *flowField) next(t *Tile) *Tile {
+	if d, ok := f.dist[t]; !ok || d == 0 {
+		return nil
+	}
+	var best *Tile
+	bestCost := math.Inf(1)
+	for _, tn := range t.openNeighbours() {
+		d, ok := f.dist[tn]
+		if !ok {
+			continue
+		}
+		if c := blockCost(t, tn) + d; c < bestCost {
+			best, bestCost = tn, c
+		}
+	}
+	return best
+}
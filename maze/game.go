@@ -2,7 +2,11 @@
 package maze
 
 import (
+	"image"
+	"time"
+
 	"github.com/hajimehoshi/ebiten/v2"
+	"oddstream.games/gomaze/metrics"
 )
 
 // Game represents a game state.
@@ -15,6 +19,10 @@ var (
 	WindowWidth int
 	// WindowHeight of main window in pixels
 	WindowHeight int
+	// Seed is set by command line flag -seed; zero means a different game every time
+	Seed int64
+	// RewindGhosts is set by command line flag -rewindghosts; if true, undo and redo move the ghosts back and forth too
+	RewindGhosts bool = false
 )
 
 // GSM provides global access to the game state manager
@@ -29,17 +37,69 @@ var TheGrid *Grid
 // TheUserData holds serialized game progress data
 var TheUserData = &UserData{Copyright: "Copyright ©️ 2021-3 oddstream.games", Game: "Herding Kittens", CompletedLevels: 0}
 
-// LevelData width,height,ghosts
-var LevelData = [][]int{
-	{7, 5, 4},
-	{7, 7, 4},
-	{9, 7, 4},
-	{11, 7, 4},
-	{13, 9, 4},
-	{15, 11, 5},
-	{17, 13, 6},
-	{19, 15, 7},
-	{21, 17, 8},
+// Level describes how to build a Grid
+type Level struct {
+	Width, Height int
+	Ghosts        int
+	Meanies       int          // number of meanies chasing the pucks, starting in the corners
+	Pucks         int          // number of pucks, each a different colour; 0 means 1
+	Walls         int          // number of walls each puck starts off carrying
+	Fuel          int          // number of walls each puck's bulldozer can knock down
+	Blocks        int          // number of blocks for the pucks to push about, put on random tiles unless BlockAt says where
+	Generator     Generator    // nil means Prim
+	Braid         float64      // fraction (0.0 .. 1.0) of dead ends to knock through, making loops
+	Difficulty    metrics.Band // mazes outside this band are thrown away and carved again
+	Topology      Topology     // shape of the tiles; nil means square
+	Mask          *Mask        // shape of the grid; if set, Width and Height come from the mask
+	Wrap          bool         // the grid wraps round like a torus, east to west and north to south
+	Floors        int          // number of floors, joined by stairs; 0 means 1
+	Terrain       TerrainMix   // how much mud, ice and conveyor to scatter over the tiles
+	Scoring       Scoring      // how finishing the level is scored; zero means defaultScoring
+
+	// where the blocks go, as X,Y of tiles on the floor with the pen, away from the pen and the corners where the meanies start
+	BlockAt []image.Point
+}
+
+// heartMask is a heart-shaped level, big enough round the middle for the pen
+var heartMask = MaskFromASCII(
+	"XXX....XXX....XXX",
+	"XX.............XX",
+	"XX.............XX",
+	"X...............X",
+	"X...............X",
+	"XX.............XX",
+	"XX.............XX",
+	"XX.............XX",
+	"XXX...........XXX",
+	"XXXX.........XXXX",
+	"XXXXX.......XXXXX",
+	"XXXXXX.....XXXXXX",
+	"XXXXXXXX.XXXXXXXX",
+)
+
+// LevelData width,height,ghosts,meanies,pucks,walls,fuel,blocks,blockat,generator,braid,difficulty,topology,mask,wrap,floors,terrain,scoring
+var LevelData = []Level{
+	{Width: 7, Height: 5, Ghosts: 4, Meanies: 1, Walls: 5, Fuel: 3, Generator: BinaryTree{}},
+	{Width: 7, Height: 7, Ghosts: 4, Meanies: 2, Walls: 4, Fuel: 3, BlockAt: []image.Point{{X: 1, Y: 3}, {X: 5, Y: 3}}, Generator: Sidewinder{}},
+	{Width: 9, Height: 7, Ghosts: 4, Meanies: 3, Walls: 4, Fuel: 3, Generator: Prim{}, Braid: 0.25, Wrap: true},
+	{Width: 11, Height: 7, Ghosts: 4, Meanies: 4, Walls: 3, Fuel: 3, Generator: Kruskal{}, Topology: HexTopology{}},
+	{Width: 13, Height: 9, Ghosts: 4, Meanies: 4, Walls: 3, Fuel: 3, Generator: Eller{}, Braid: 0.5, Terrain: TerrainMix{Mud: 0.1, Ice: 0.08, Conveyor: 0.03}},
+	{Width: 15, Height: 11, Ghosts: 5, Meanies: 4, Walls: 3, Fuel: 2, Blocks: 3, Generator: GrowingTree{Newest: 0.5}, Difficulty: metrics.Band{Min: 8, Max: 12}},
+	{Height: 7, Ghosts: 6, Meanies: 4, Walls: 2, Fuel: 2, Generator: Wilson{}, Braid: 0.5, Topology: PolarTopology{}},
+	{Ghosts: 6, Meanies: 4, Walls: 2, Fuel: 2, Generator: HuntAndKill{}, Braid: 0.25, Mask: heartMask},
+	{Width: 19, Height: 15, Ghosts: 7, Meanies: 4, Pucks: 2, Walls: 2, Fuel: 2, Generator: RoomsAndMazes{Attempts: 30, MinSize: 2, MaxSize: 4, Doors: 2}, Difficulty: metrics.Band{Min: 12, Max: 20}},
+	{Width: 21, Height: 17, Ghosts: 8, Meanies: 4, Pucks: 3, Walls: 1, Fuel: 1, Blocks: 4, Generator: RecursiveBacktracker{}, Difficulty: metrics.Band{Min: 20, Max: 45}, Scoring: Scoring{Base: 20000, PerSecond: 5, PerMove: 1, PerThrow: 10, PerToggle: 100}},
+	{Width: 15, Height: 11, Ghosts: 8, Meanies: 4, Pucks: 2, Walls: 2, Fuel: 2, Generator: Kruskal{Weave: 0.2}, Braid: 0.25, Floors: 3},
+}
+
+// LevelSeed returns the seed used to build a level.
+// If a Seed was given, each level gets its own seed derived from it,
+// so a whole run can be replayed (or shared as a daily challenge).
+func LevelSeed(lvl int) int64 {
+	if Seed == 0 {
+		return time.Now().UnixNano()
+	}
+	return Seed + int64(lvl)
 }
 
 // NewGame generates a new Game object.
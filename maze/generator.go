@@ -0,0 +1,48 @@
+package maze
+
+import (
+	"log"
+	"math/rand"
+)
+
+// Generator is the interface for maze carving algorithms.
+// Generate is given a Grid where every tile starts with all its walls
+// (apart from the pen, which has already been opened up) and knocks
+// walls down until every tile is reachable. Tile.visited may be used
+// as scratch space; it is reset by visitTiles after carving.
+type Generator interface {
+	Generate(g *Grid, rng *rand.Rand)
+}
+
+// randomNeighbour returns a direction leading to a random neighbour of t
+// for which fn returns true, or -1 if there are none
+func randomNeighbour(t *Tile, rng *rand.Rand, fn func(*Tile) bool) Direction {
+	var dirs []Direction
+	for _, d := range ALL_DIRECTIONS {
+		if tn := t.neighbour(d); tn != nil && fn(tn) {
+			dirs = append(dirs, d)
+		}
+	}
+	if len(dirs) == 0 {
+		return -1
+	}
+	return dirs[rng.Intn(len(dirs))]
+}
+
+// needSquareTiles stops generators that work in rows and columns of square tiles
+// from being used with any other topology, with a mask that leaves holes in the rows,
+// or on a grid that wraps round, where rows and columns have no ends
+func needSquareTiles(g *Grid, name string) {
+	if _, ok := TheTopology.(SquareTopology); !ok {
+		log.Fatal(name, " only works with square tiles")
+	}
+	if g.masked {
+		log.Fatal(name, " does not work with masks")
+	}
+	if g.wrap {
+		log.Fatal(name, " does not work with wrapped grids")
+	}
+}
+
+func isVisited(t *Tile) bool   { return t.visited }
+func isUnvisited(t *Tile) bool { return !t.visited }
@@ -1,7 +1,9 @@
 package maze
 
 import (
+	"math"
 	"math/rand"
+	"sort"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"oddstream.games/gomaze/util"
@@ -21,47 +23,25 @@ func init() {
 	directionlessGhostImage = ebiten.NewImageFromImage(makeNPCImage(-1, ""))
 }
 
-// Forward returns the direction (0-3)
-func Forward(dir Direction) Direction {
-	return dir
-}
-
-// Backward returns the direction (0-3)
-func Backward(dir Direction) Direction {
-	d := [4]Direction{SOUTH, WEST, NORTH, EAST}
-	return d[dir]
-}
-
-// Leftward returns the direction (0-3)
-func Leftward(dir Direction) Direction {
-	d := [4]Direction{WEST, NORTH, EAST, SOUTH}
-	return d[dir]
-}
-
-// Rightward returns the direction (0-3)
-func Rightward(dir Direction) Direction {
-	d := [4]Direction{EAST, SOUTH, WEST, NORTH}
-	return d[dir]
-}
-
-type dirfunc func(Direction) Direction
-
 // Ghost is a direction-following NPC that tries to avoid the Puck
 type Ghost struct {
-	tile                   *Tile     // tile we are sitting on
-	dest                   *Tile     // tile we are lerping to
-	facing                 Direction // NORTH || EAST || SOUTH || WEST
-	srcX, srcY, dstX, dstY float64   // positions for lerp
+	tile                   *Tile   // tile we are sitting on
+	dest                   *Tile   // tile we are lerping to
+	prev                   *Tile   // tile we have just come from, so ice knows which way we were going
+	heading                float64 // angle we are facing, radians clockwise from east
+	srcX, srcY, dstX, dstY float64 // positions for lerp
 	lerpstep               float64
 	speed                  float64
 	worldX, worldY         float64
+	climbed                bool       // just came up or down stairs, so don't go straight back
+	rng                    *rand.Rand // the grid's random number generator, so ghosts are reproducible
 }
 
 // NewGhost creates a new Ghost object
-func NewGhost(start *Tile) *Ghost {
-	gh := &Ghost{tile: start}
-	gh.facing = Direction(rand.Intn(3))
-	gh.speed = 0.01 + (rand.Float64() * 0.02)
+func NewGhost(start *Tile, rng *rand.Rand) *Ghost {
+	gh := &Ghost{tile: start, rng: rng}
+	gh.heading = TheTopology.WallAngle(gh.tile, Direction(gh.rng.Intn(3)))
+	gh.speed = 0.01 + (gh.rng.Float64() * 0.02)
 	gh.worldX, gh.worldY = gh.tile.position()
 	return gh
 }
@@ -83,8 +63,13 @@ func (gh *Ghost) isDirOkay(d Direction) bool {
 
 	tn := gh.tile.neighbour(d)
 
-	// don't like puck
-	if tn == TheGrid.puck.tile || tn == TheGrid.puck.dest {
+	// don't like pucks
+	if TheGrid.puckOn(tn) {
+		return false
+	}
+
+	// can't get past blocks
+	if TheGrid.blockOn(tn) {
 		return false
 	}
 
@@ -109,40 +94,82 @@ func (gh *Ghost) isDirOkay(d Direction) bool {
 	return true
 }
 
+// preferredDirections returns the directions out of the current tile in the order
+// the ghost would like to try them: turning left (or right) first, then straight on,
+// then turning right (or left), and going back the way it came last of all.
+// On square tiles that's left, forward, right, back.
+func (gh *Ghost) preferredDirections(leftFirst bool) []Direction {
+	type turn struct {
+		d     Direction
+		angle float64
+	}
+	var turns []turn
+	var back []Direction
+	for _, d := range ALL_DIRECTIONS {
+		a := angleBetween(gh.heading, TheTopology.WallAngle(gh.tile, d))
+		if math.Abs(a) > math.Pi*5/6 {
+			back = append(back, d)
+		} else {
+			turns = append(turns, turn{d: d, angle: a})
+		}
+	}
+	// a negative angle is a turn to the left, as y increases down the screen
+	sort.SliceStable(turns, func(i, j int) bool {
+		if leftFirst {
+			return turns[i].angle < turns[j].angle
+		}
+		return turns[i].angle > turns[j].angle
+	})
+	dirs := make([]Direction, 0, len(ALL_DIRECTIONS))
+	for _, t := range turns {
+		dirs = append(dirs, t.d)
+	}
+	return append(dirs, back...)
+}
+
 // Update the state/position of the Ghost
 func (gh *Ghost) Update() error {
 
 	if gh.dest == nil {
-		var dirfuncs [4]dirfunc
-		if rand.Float64() < 0.5 {
-			dirfuncs = [4]dirfunc{Leftward, Forward, Rightward, Backward}
+		next := gh.tile.forcedMove(gh.prev)
+		gh.prev = nil
+		if next != nil {
+			// the terrain decides where to go, even if the puck is there
+			if d := whichDirIs(gh.tile, next); d >= 0 {
+				gh.heading = TheTopology.WallAngle(gh.tile, d)
+			}
+			gh.dest = next
+		} else if st := gh.tile.stairs; st != nil && !gh.climbed && !TheGrid.puckOn(st) && !TheGrid.blockOn(st) && gh.rng.Float64() < 0.5 {
+			// sometimes take the stairs, if there are some here and there isn't a puck or block at the other end
+			gh.dest = st
+			gh.climbed = true
 		} else {
-			dirfuncs = [4]dirfunc{Rightward, Forward, Leftward, Backward}
-		}
-		for d := 0; d < 4; d++ {
-			newd := dirfuncs[d](gh.facing)
-			if gh.isDirOkay(newd) {
-				gh.facing = newd
-				gh.dest = gh.tile.neighbour(newd)
-				break
+			gh.climbed = false
+			for _, newd := range gh.preferredDirections(gh.rng.Float64() < 0.5) {
+				if gh.isDirOkay(newd) {
+					gh.heading = TheTopology.WallAngle(gh.tile, newd)
+					gh.dest = gh.tile.neighbour(newd)
+					break
+				}
 			}
 		}
 		if gh.dest != nil {
 			gh.lerpstep = 0
 			gh.srcX, gh.srcY = gh.tile.position()
-			gh.dstX, gh.dstY = gh.dest.position()
+			gh.dstX, gh.dstY = gh.dest.positionNear(gh.srcX, gh.srcY)
 		}
 		// if gh.dest == nil, ghost has no direction
 		// this can happen when trying to stop ghosts from sitting on top of each other in Ghost.IsGoodDir()
 	} else {
 		if gh.lerpstep >= 1 {
+			gh.prev = gh.tile
 			gh.tile = gh.dest
 			gh.worldX, gh.worldY = gh.tile.position()
 			gh.dest = nil
 		} else {
 			gh.worldX = util.Lerp(gh.srcX, gh.dstX, gh.lerpstep)
 			gh.worldY = util.Lerp(gh.srcY, gh.dstY, gh.lerpstep)
-			gh.lerpstep += gh.speed
+			gh.lerpstep += gh.speed * gh.dest.terrain.speed()
 		}
 	}
 
@@ -157,6 +184,7 @@ func (gh *Ghost) Draw(screen *ebiten.Image) {
 	if gh.dest == nil {
 		screen.DrawImage(directionlessGhostImage, op)
 	} else {
-		screen.DrawImage(ghostImages[int(gh.facing)], op)
+		// ghost images only look four ways, so pick the nearest
+		screen.DrawImage(ghostImages[int(math.Round(gh.heading/(math.Pi/2))+5)%4], op)
 	}
 }
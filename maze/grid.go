@@ -5,12 +5,16 @@ import (
 	"image"
 	"image/color"
 	"log"
+	"math"
 	"math/rand"
 	"runtime"
 
 	"github.com/fogleman/gg"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"golang.org/x/image/font"
+	"oddstream.games/gomaze/metrics"
 	"oddstream.games/gomaze/util"
 )
 
@@ -19,6 +23,16 @@ const (
 	TileSize = 80
 	// MaxGhosts limit that can fit in 3x3 pen
 	MaxGhosts = 8
+	// MaxMeanies is one for each corner of the maze
+	MaxMeanies = 4
+	// MaxPucks is one for each of puckColors
+	MaxPucks = 4
+	// refusedFlashTicks is how long the walls counter flashes when there's no wall to place
+	refusedFlashTicks = 45
+	// maxBuildAttempts is how many mazes to try carving before giving up on finding one within the level's difficulty band
+	maxBuildAttempts = 20
+	// stairsPerFloor is how many staircases join each floor to the one above it
+	stairsPerFloor = 2
 )
 
 // TilesAcross and TilesDown are package-level variables so they can be seen by Tile
@@ -27,26 +41,60 @@ var (
 	TilesDown   int
 	CameraX     float64
 	CameraY     float64
+	// wrapWidth and wrapHeight are how far apart (in pixels) the copies of a world that wraps round
+	// like a torus are; they're zero if the grid doesn't wrap
+	wrapWidth  float64
+	wrapHeight float64
 )
 
 // Grid is an object representing the grid of tiles
 type Grid struct {
+	level              int     // index into LevelData
 	ticks              int     // count update ticks (use ticks % 10 for refreshing minimap)
-	tiles              []*Tile // a slice (not array!) of pointers to Tile objects
+	tally              Tally   // what the player has done so far, for the score
+	scoring            Scoring // how the level is scored, from its Level
+	seed               int64   // the seed this grid was built from, for reproducing bugs
+	rng                *rand.Rand
+	loops              int       // number of loops added by braiding
+	tiles              []*Tile   // a slice (not array!) of pointers to Tile objects, on every floor
+	rows               [][]*Tile // tiles by Y then X, set by Topology.Link; rows need not be the same length
+	floors             []*Grid   // each floor is a Grid of its own tiles and rows, for carving; floor 0 has the pen
+	masked             bool      // some tiles have been cut out by the level's Mask, so rows have holes
+	wrap               bool      // east edge is linked to west, and north to south
 	colorBackground    color.RGBA
 	colorWall          color.RGBA
 	input              *Input
-	puck               *Puck
+	buttons            *Input // the undo and redo buttons only hear about taps that Grid passes on to them
+	undoButton         *TextButton
+	wallMenu           *RadialMenu // open on the active puck, if it's been tapped while standing still
+	redoButton         *TextButton
+	history            history
+	pucks              []*Puck
+	activePuck         *Puck   // the puck that follows taps, moves walls, and has the camera on it
+	dragPuck           *Puck   // the puck being dragged, if any
+	dragPath           []*Tile // the way dragPuck would go if it was dropped where the pointer is now
 	ghosts             []*Ghost
 	meanies            []*Meanie
+	blocks             []*Block
 	penImage           *ebiten.Image
 	penX, penY         float64
 	minimapImage       *ebiten.Image
+	flow               *flowField // leads the meanies to the puck; nil when it needs working out again
+	componentSizes     []int      // number of tiles in each component, indexed by Tile.component
+	refusedTicks       int        // counts down while the walls counter flashes, after trying to place a wall without one
 	minimapX, minimapY float64
+
+	report    metrics.Report // how difficult the maze that was kept is
+	attempts  int            // number of mazes carved to find one within the level's difficulty band
+	outOfBand bool           // no maze was found within the band, so the last one was kept anyway
 }
 
-// NewGrid create a Grid object
-func NewGrid(w, h, ghostCount int) *Grid {
+// NewGrid create a Grid object for a level, built to its LevelData settings.
+// All randomness (carving, ghosts, palette) comes from seed, so the same seed
+// and the same player input will always give the same game.
+func NewGrid(level int, seed int64) *Grid {
+
+	lvl := LevelData[level]
 
 	// var screenWidth, screenHeight int
 
@@ -56,203 +104,414 @@ func NewGrid(w, h, ghostCount int) *Grid {
 	// 	screenWidth, screenHeight = ebiten.WindowSize()
 	// }
 
-	TilesAcross, TilesDown = w, h
-
-	g := &Grid{tiles: make([]*Tile, TilesAcross*TilesDown)}
-	for i := range g.tiles {
-		g.tiles[i] = NewTile(i%TilesAcross, i/TilesAcross)
+	TilesAcross, TilesDown = lvl.Width, lvl.Height
+	if lvl.Mask != nil {
+		TilesAcross, TilesDown = lvl.Mask.Size()
 	}
+	setTopology(lvl.Topology)
 
-	// link the tiles together to avoid all that tedious 2d array stuff
-	for _, t := range g.tiles {
-		x := t.X
-		y := t.Y
-		t.edges[0] = g.findTile(x, y-1) // North
-		t.edges[1] = g.findTile(x+1, y) // East
-		t.edges[2] = g.findTile(x, y+1) // South
-		t.edges[3] = g.findTile(x-1, y) // West
+	g := &Grid{level: level, seed: seed, rng: rand.New(rand.NewSource(seed)), wrap: lvl.Wrap, scoring: lvl.Scoring}
+	if g.wrap && (TilesAcross < 3 || TilesDown < 3) {
+		log.Fatal("a wrapped grid needs to be at least 3x3")
 	}
+	floors := lvl.Floors
+	if floors < 1 {
+		floors = 1
+	}
+	for i := 0; i < floors; i++ {
+		f := &Grid{rng: g.rng, wrap: g.wrap}
+		TheTopology.Link(f)
+		if i == 0 {
+			wrapWidth, wrapHeight = 0, 0
+			if g.wrap {
+				// the distance between neighbouring tiles, times the number of tiles
+				wrapWidth = (f.rows[0][1].worldX - f.rows[0][0].worldX) * float64(TilesAcross)
+				wrapHeight = (f.rows[1][0].worldY - f.rows[0][0].worldY) * float64(TilesDown)
+			}
+		}
+		if lvl.Mask != nil {
+			// every floor keeps the middle, so the floors have somewhere in common to put stairs
+			f.applyMask(lvl.Mask, TheTopology.Pen(f))
+			g.masked = true
+		}
+		for _, t := range f.tiles {
+			t.floor = i
+		}
+		g.floors = append(g.floors, f)
+		g.tiles = append(g.tiles, f.tiles...)
+	}
+	// the pen and the puck's start are found in the rows of the ground floor
+	g.rows = g.floors[0].rows
 
 	{
-		// the pen is a 3x3 square of tiles in the middle of the grid
-		midX := TilesAcross / 2
-		midY := TilesDown / 2
-		for x := midX - 1; x <= midX+1; x++ {
-			for y := midY - 1; y <= midY+1; y++ {
-				t := g.findTile(x, y)
-				t.removeAllWalls()
-				t.pen = true
+		pen := TheTopology.Pen(g)
+		for _, t := range pen {
+			t.pen = true
+		}
+
+		// find the extent of the pen in world coords
+		bounds := image.Rectangle{}
+		for i, t := range pen {
+			for _, pt := range TheTopology.Outline(t) {
+				r := image.Rectangle{Min: pt, Max: pt.Add(image.Point{X: 1, Y: 1})}
+				if i == 0 && bounds.Empty() {
+					bounds = r
+				} else {
+					bounds = bounds.Union(r)
+				}
 			}
 		}
-		// create a shaded background image for the pen
-		dc := gg.NewContext(TileSize*3, TileSize*3)
+
+		// create a shaded background image for the pen, the same shape as the pen tiles
+		dc := gg.NewContext(bounds.Dx(), bounds.Dy())
 		dc.SetRGBA(float64(g.colorBackground.R/0xff), float64(g.colorBackground.G/0xff), float64(g.colorBackground.B/0xff), 0.5)
-		dc.DrawRoundedRectangle(0, 0, float64(TileSize*3), float64(TileSize*3), float64(TileSize/12))
+		for _, t := range pen {
+			for _, pt := range TheTopology.Outline(t) {
+				dc.LineTo(float64(pt.X-bounds.Min.X), float64(pt.Y-bounds.Min.Y))
+			}
+			dc.ClosePath()
+		}
 		dc.Fill()
 		dc.Stroke()
 
 		// put the level number dimly in the background image
 		dc.SetRGBA(float64(g.colorBackground.R/0xff), float64(g.colorBackground.G/0xff), float64(g.colorBackground.B/0xff), 0.1)
 		dc.SetFontFace(TheAcmeFonts.huge)
-		dc.DrawStringAnchored(fmt.Sprint(TheUserData.CompletedLevels+1), float64(TileSize)*1.5, float64(TileSize), 0.5, 0.5)
+		dc.DrawStringAnchored(fmt.Sprint(g.level+1), float64(bounds.Dx())/2, float64(TileSize), 0.5, 0.5)
 
 		g.penImage = ebiten.NewImageFromImage(dc.Image())
 
 		// remember where to draw the pen background image
-		t := g.findTile(midX-1, midY-1)
-		x, y, _, _ := t.rect()
-		g.penX = float64(x)
-		g.penY = float64(y)
+		g.penX = float64(bounds.Min.X)
+		g.penY = float64(bounds.Min.Y)
 	}
 
-	g.carve()
+	g.placeStairs()
+	g.placeTerrain(lvl.Terrain)
+
+	// keep carving until the maze is the right sort of difficult for this level
+	for g.attempts = 1; ; g.attempts++ {
+		g.build(lvl)
+		g.report = g.Metrics()
+		if lvl.Difficulty.Contains(g.report) {
+			break
+		}
+		if g.attempts == maxBuildAttempts {
+			// make do with the last maze, but say so, as the level's band may be one that can't be met
+			g.outOfBand = true
+			log.Printf("level %d seed %d: no maze within difficulty band after %d attempts, using %s",
+				level+1, seed, maxBuildAttempts, g.report.String())
+			break
+		}
+	}
 
-	g.puck = NewPuck(g.findTile(TilesAcross/2, TilesDown/2), BasicColors["Yellow"])
+	{
+		// the first puck starts in the middle of the pen, any others around it
+		pen := TheTopology.Pen(g)
+		for i := 0; i < max(lvl.Pucks, 1) && i < MaxPucks; i++ {
+			p := NewPuck(pen[i], BasicColors[puckColors[i]], 1<<i)
+			p.walls = lvl.Walls
+			p.fuel = lvl.Fuel
+			g.pucks = append(g.pucks, p)
+		}
+		g.activePuck = g.pucks[0]
+		g.activePuck.SetCamera()
+	}
 
+	// meanies start in the corners, clockwise from the top left
+	{
+		w, h := TheTopology.Size()
+		corners := [MaxMeanies][2]float64{{0, 0}, {float64(w), 0}, {float64(w), float64(h)}, {0, float64(h)}}
+		for i := 0; i < lvl.Meanies && i < MaxMeanies; i++ {
+			g.meanies = append(g.meanies, NewMeanie(g.nearestTile(corners[i][0], corners[i][1]), g.rng))
+		}
+	}
+
+	// the blocks go down before the ghosts, as a level may say where they go
+	g.placeBlocks(lvl)
+
+	ghostCount := lvl.Ghosts
 	if ghostCount > MaxGhosts {
 		ghostCount = MaxGhosts
 	}
 	for i := 0; i < ghostCount; i++ {
-		g.ghosts = append(g.ghosts, NewGhost(g.randomTile()))
+		g.ghosts = append(g.ghosts, NewGhost(g.randomTile(), g.rng))
 	}
 
-	palette := Palettes[rand.Int()%len(Palettes)]
+	palette := Palettes[g.rng.Int()%len(Palettes)]
 	g.colorBackground = CalcBackgroundColor(palette)
-	g.colorWall = ExtendedColors[palette[rand.Int()%len(palette)]]
+	g.colorWall = ExtendedColors[palette[g.rng.Int()%len(palette)]]
 
 	g.input = NewInput()
 	g.input.Add(g)
 
+	g.buttons = NewInput()
+	g.undoButton = NewTextButton("UNDO", 100, 40, TheAcmeFonts.normal, func() { g.Undo() }, g.buttons)
+	g.redoButton = NewTextButton("REDO", 100, 40, TheAcmeFonts.normal, func() { g.Redo() }, g.buttons)
+
 	return g
 }
 
 // NotifyCallback is called by the Subject (Input) when something interesting happens
 func (g *Grid) NotifyCallback(event interface{}) {
+	if g.wallMenu != nil {
+		// the wall menu has the pointer to itself while it's open, and Escape or Backspace close it
+		switch v := event.(type) {
+		case PointerEvent, image.Point:
+			g.wallMenu.NotifyCallback(event)
+			return
+		case ebiten.Key:
+			if v == ebiten.KeyEscape || v == ebiten.KeyBackspace {
+				g.closeWallMenu()
+				return
+			}
+		}
+	}
 	switch v := event.(type) { // Type switch https://tour.golang.org/methods/16
+	case PointerEvent:
+		// the active puck can be dragged to where it should go, rather than throwing the ball there
+		t := g.findTileAt(g.worldPoint(v.Pt))
+		if g.onButton(v.Pt) {
+			t = nil
+		}
+		switch v.Kind {
+		case PointerPress:
+			if t != nil && t == g.activePuck.tile {
+				g.dragPuck = g.activePuck
+			}
+		case PointerDrag:
+			if g.dragPuck != nil && t != nil && (len(g.dragPath) == 0 || t != g.dragPath[len(g.dragPath)-1]) {
+				g.dragPath = g.dragPuck.route(g.dragPuck.tile, t)
+			}
+		case PointerRelease:
+			if g.dragPuck != nil && v.Dragged && t != nil && t != g.dragPuck.tile {
+				g.beginStep()
+				g.dragPuck.clearPath()
+				g.dragPuck.DropAt(t)
+			}
+			g.dragPuck, g.dragPath = nil, nil
+		}
 	case image.Point:
-		pt := v
-		pt.X = pt.X - int(CameraX)
-		pt.Y = pt.Y - int(CameraY)
-		// pt = pt.Sub(image.Point{X: int(CameraX), Y: int(CameraY)})
+		if g.onButton(v) {
+			// the buttons aren't observers of g.input, as they'd hear about Backspace too
+			g.buttons.Notify(v)
+			return
+		}
+		pt := g.worldPoint(v)
 		t := g.findTileAt(pt)
 		if t != nil {
-			if t == g.puck.tile {
-				// println("Puck is already on tile", t.X, t.Y, t.WhichQuadrant(pt))
-				dir := t.whichQuadrant(pt)
-				if t.neighbour(dir) != nil {
-					if t.isWall(dir) {
-						t.removeWall(dir)
-					} else {
-						t.addWall(dir)
-					}
-				}
-				// g.puck.tile.toggleWall(t.whichQuadrant(pt))
-				g.visitTiles()
+			if t == g.activePuck.tile && g.activePuck.dest == nil && !g.activePuck.dozing {
+				g.openWallMenu()
+			} else if p := g.puckAt(t); p != nil {
+				// tapping another puck makes it the active one
+				g.activePuck = p
+				p.SetCamera()
 			} else {
 				// println("input on tile", t.X, t.Y, t.wallCount())
-				g.AllTiles(func(t *Tile) { t.parent = nil; t.marked = false })
-				g.puck.ThrowBallTo(t)
+				g.beginStep()
+				g.activePuck.clearPath()
+				g.activePuck.ThrowBallTo(t)
 			}
 		}
 	case ebiten.Key:
 		k := v
+		// these keys have the active puck do something, which can be undone
+		switch k {
+		case ebiten.KeyW, ebiten.KeyD, ebiten.KeyS, ebiten.KeyA,
+			ebiten.KeyArrowUp, ebiten.KeyArrowRight, ebiten.KeyArrowDown, ebiten.KeyArrowLeft:
+			g.beginStep()
+		}
 		switch k {
 		case ebiten.KeyBackspace:
 			GSM.Switch(NewMenu())
 		case ebiten.KeyW:
-			g.puck.tile.toggleWall(0)
-			g.visitTiles()
+			g.puckToggleWall(g.activePuck.tile.wallTowards(-math.Pi / 2))
 		case ebiten.KeyD:
-			g.puck.tile.toggleWall(1)
-			g.visitTiles()
+			g.puckToggleWall(g.activePuck.tile.wallTowards(0))
 		case ebiten.KeyS:
-			g.puck.tile.toggleWall(2)
-			g.visitTiles()
+			g.puckToggleWall(g.activePuck.tile.wallTowards(math.Pi / 2))
 		case ebiten.KeyA:
-			g.puck.tile.toggleWall(3)
-			g.visitTiles()
+			g.puckToggleWall(g.activePuck.tile.wallTowards(math.Pi))
+		case ebiten.KeyArrowUp:
+			g.activePuck.Bulldoze(-math.Pi / 2)
+		case ebiten.KeyArrowRight:
+			g.activePuck.Bulldoze(0)
+		case ebiten.KeyArrowDown:
+			g.activePuck.Bulldoze(math.Pi / 2)
+		case ebiten.KeyArrowLeft:
+			g.activePuck.Bulldoze(math.Pi)
+		case ebiten.KeyZ:
+			g.Undo()
+		case ebiten.KeyY:
+			g.Redo()
 		case ebiten.KeyM:
-			g.meanies = append(g.meanies, NewMeanie(g.randomTile()))
+			g.meanies = append(g.meanies, NewMeanie(g.randomTile(), g.rng))
 		}
 	}
 }
 
-// Size returns the size of the grid in pixels
-func (g *Grid) Size() (int, int) {
-	return TilesAcross * TileSize, TilesDown * TileSize
+// beginStep starts a new step in the history, as the player is doing something
+func (g *Grid) beginStep() {
+	g.history.begin(g.ghostStates())
 }
 
-func (g *Grid) findTile(x, y int) *Tile {
+// openWallMenu pops up a RadialMenu around the active puck, for picking up and putting down its walls
+func (g *Grid) openWallMenu() {
+	g.wallMenu = NewRadialMenu(g.activePuck, func(d Direction) {
+		g.beginStep()
+		g.puckToggleWall(d)
+	}, g.closeWallMenu)
+	g.placeWallMenu()
+}
 
-	// we re-order the tiles when dragging, to put the dragged tile at the top of the z-order
-	// so we can't use i := x + (y * TilesAcross) to find index of tile in slice
-	// except if this func is just used after tiles have been created
+// placeWallMenu keeps the wall menu around the active puck, wherever the camera has put it on the screen
+func (g *Grid) placeWallMenu() {
+	p := g.activePuck
+	g.wallMenu.SetPosition(int(p.worldX+TileSize/2+CameraX), int(p.worldY+TileSize/2+CameraY))
+}
 
-	// for _, t := range g.tiles {
-	// 	if t.X == x && t.Y == y {
-	// 		return t
-	// 	}
-	// }
-	// return nil
+// closeWallMenu gets rid of the wall menu
+func (g *Grid) closeWallMenu() {
+	g.wallMenu = nil
+}
 
-	if x < 0 || x >= TilesAcross {
-		return nil
+// onButton returns true if pt (in screen coords) is on the undo or redo button
+func (g *Grid) onButton(pt image.Point) bool {
+	return util.InRect(pt, g.undoButton.Rect) || util.InRect(pt, g.redoButton.Rect)
+}
+
+// puckToggleWall has the active puck pick up or put down a wall; if it
+// hasn't got a wall to put down, the walls counter flashes to say so
+func (g *Grid) puckToggleWall(d Direction) {
+	if !g.activePuck.toggleWall(d) {
+		g.refusedTicks = refusedFlashTicks
+	}
+}
+
+// worldPoint converts a point on the screen to world coords
+func (g *Grid) worldPoint(pt image.Point) image.Point {
+	pt.X = pt.X - int(CameraX)
+	pt.Y = pt.Y - int(CameraY)
+	// pt = pt.Sub(image.Point{X: int(CameraX), Y: int(CameraY)})
+	if g.wrap {
+		// use the copy of the world that the active puck is in the middle of
+		px, py := g.activePuck.tile.center()
+		pt.X = int(wrapNear(float64(pt.X), px, wrapWidth))
+		pt.Y = int(wrapNear(float64(pt.Y), py, wrapHeight))
+	}
+	return pt
+}
+
+// toggleWall adds or removes the wall of t in direction d, keeping track
+// of which tiles can be reached, and throwing away the meanies' flow field and the minimap
+func (g *Grid) toggleWall(t *Tile, d Direction) {
+	tn := t.neighbour(d)
+	if tn == nil {
+		return
 	}
-	if y < 0 || y >= TilesDown {
+	if t.isWall(d) {
+		t.removeWall(d)
+		g.joined(t, tn)
+	} else {
+		t.addWall(d)
+		g.parted(t, tn)
+	}
+	g.flow = nil
+	g.minimapImage = nil
+	if DebugMode {
+		g.checkReachability()
+	}
+}
+
+// Size returns the size of the grid in pixels
+func (g *Grid) Size() (int, int) {
+	return TheTopology.Size()
+}
+
+// findTile returns the tile at X,Y, or nil if there isn't one;
+// if the grid wraps, X,Y off one edge are found on the opposite edge
+func (g *Grid) findTile(x, y int) *Tile {
+	if g.wrap {
+		x = (x%TilesAcross + TilesAcross) % TilesAcross
+		y = (y%TilesDown + TilesDown) % TilesDown
+	}
+	if y < 0 || y >= len(g.rows) {
 		return nil
 	}
-	i := x + (y * TilesAcross)
-	if i < 0 || i > len(g.tiles) {
-		log.Fatal("findTile index out of bounds")
+	if x < 0 || x >= len(g.rows[y]) {
+		return nil
 	}
-	return g.tiles[i]
+	return g.rows[y][x]
 }
 
-// findTileAt finds the tile under the mouse click or touch
+// findTileAt finds the tile under the mouse click or touch, on the floor being shown
 func (g *Grid) findTileAt(pt image.Point) *Tile {
-	for _, t := range g.tiles {
-		if util.InRect(pt, t.rect) {
+	tiles := g.floors[g.floor()].tiles
+	for _, t := range tiles {
+		if t.contains(pt) {
 			return t
 		}
 	}
+	if g.wrap {
+		// tiles on the far edges may stick out past the seam, into the next copy of the world
+		w, h := int(wrapWidth), int(wrapHeight)
+		for _, off := range []image.Point{{X: w}, {Y: h}, {X: w, Y: h}, {X: -w}, {Y: -h}, {X: -w, Y: -h}, {X: w, Y: -h}, {X: -w, Y: h}} {
+			for _, t := range tiles {
+				if t.contains(pt.Add(off)) {
+					return t
+				}
+			}
+		}
+	}
 	return nil
 }
 
-func (g *Grid) randomTile() *Tile {
-	i := rand.Intn(len(g.tiles))
-	return g.tiles[i]
+// nearestTile returns the reachable tile on the ground floor, outside the pen, whose middle is nearest x,y (in world coords)
+func (g *Grid) nearestTile(x, y float64) *Tile {
+	var best *Tile
+	bestDist := math.Inf(1)
+	for _, t := range g.floors[0].tiles {
+		if !t.visited || t.pen {
+			continue
+		}
+		cx, cy := t.center()
+		if d := math.Hypot(cx-x, cy-y); d < bestDist {
+			best, bestDist = t, d
+		}
+	}
+	return best
 }
 
-// BFS does a breadth first search and sets Tile.parent
-// to form a path from start to dest tiles.
-func (g *Grid) BFS(start, dst *Tile) {
-	g.AllTiles(func(t *Tile) { t.parent = nil })
-	q := []*Tile{start}
-	start.parent = start
-	for len(q) > 0 {
-		t := q[0]
-		q = q[1:] // take first tile off front of queue
-		if t == dst {
-			return
+// puckAt returns the puck sitting on t, or nil if there isn't one
+func (g *Grid) puckAt(t *Tile) *Puck {
+	for _, p := range g.pucks {
+		if p.tile == t {
+			return p
 		}
-		for _, d := range ALL_DIRECTIONS {
-			tn := t.neighbour(d)
-			if tn != nil && !t.isWall(d) && tn.parent == nil {
-				tn.parent = t
-				q = append(q, tn)
-			}
+	}
+	return nil
+}
+
+// puckOn returns true if any puck is on t, or on its way there
+func (g *Grid) puckOn(t *Tile) bool {
+	for _, p := range g.pucks {
+		if p.tile == t || p.dest == t {
+			return true
 		}
 	}
-	// if puck has walled a ghost in, then a path will not be found
-	// log.Println("Grid.BFS: path not found")
+	return false
 }
 
-func (g *Grid) findTileTowards(src, dst *Tile) *Tile {
-	g.BFS(src, dst)
-	for dst != nil && dst.parent != src {
-		dst = dst.parent
+// randomTile returns a random tile that can be reached from the pen, and hasn't got a block on it;
+// some generators leave solid, walled-in tiles that nothing should start on
+func (g *Grid) randomTile() *Tile {
+	for _, i := range g.rng.Perm(len(g.tiles)) {
+		if t := g.tiles[i]; t.visited && !g.blockOn(t) {
+			return t
+		}
 	}
-	return dst
+	log.Fatal("nowhere reachable from the pen to start")
+	return nil
 }
 
 // func (g *Grid) removeRandomWall() {
@@ -265,94 +524,150 @@ func (g *Grid) findTileTowards(src, dst *Tile) *Tile {
 // 	}
 // }
 
-// func (g *Grid) createRoom(x1, y1 int) {
-// 	x2 := x1 + 4
-// 	y2 := y1 + 4
-// 	if x2 >= TilesAcross || y2 >= TilesDown {
-// 		return
-// 	}
-// 	for x := x1; x < x2; x++ {
-// 		for y := y1; y < y2; y++ {
-// 			t := g.findTile(x, y)
-// 			t.removeAllWalls()
-// 		}
-// 	}
-// 	for x := x1; x < x2; x++ {
-// 		t := g.findTile(x, y1)
-// 		t.addWall(0)
-// 		t = g.findTile(x, y2-1)
-// 		t.addWall(2)
-// 	}
-// 	for y := y1; y < y2; y++ {
-// 		t := g.findTile(x1, y)
-// 		t.addWall(3)
-// 		t = g.findTile(x2-1, y)
-// 		t.addWall(1)
-// 	}
-// }
+// placeStairs joins each floor to the one above with stairsPerFloor pairs of stacked tiles,
+// away from the pen; the stairs stay put when the maze is rebuilt
+func (g *Grid) placeStairs() {
+	for i := 0; i+1 < len(g.floors); i++ {
+		below, above := g.floors[i], g.floors[i+1]
+		placed := 0
+		for _, j := range g.rng.Perm(len(below.tiles)) {
+			if placed == stairsPerFloor {
+				break
+			}
+			t := below.tiles[j]
+			if t.pen || t.stairs != nil {
+				continue
+			}
+			if ta := above.findTile(t.X, t.Y); ta != nil && ta.stairs == nil {
+				t.stairs, ta.stairs = ta, t
+				placed++
+			}
+		}
+		if placed == 0 {
+			log.Fatal("nowhere to put stairs between floors ", i, " and ", i+1)
+		}
+	}
+}
 
-// func (g *Grid) createRooms() {
-// 	for x := 0; x < TilesAcross; x += 4 {
-// 		for y := 0; y < TilesDown; y += 4 {
-// 			if rand.Float64() < 0.5 {
-// 				g.createRoom(x, y)
-// 			}
-// 		}
-// 	}
-// }
+// build (re)makes the maze from scratch; the pen is opened up, each floor is carved,
+// the whole maze braided, and then reachable tiles are marked
+func (g *Grid) build(lvl Level) {
+	for _, t := range g.tiles {
+		if t.under != nil {
+			t.removeTunnel()
+		}
+		t.walls = t.allWalls()
+		t.visited = false
+	}
+	for _, t := range g.tiles {
+		if t.pen {
+			t.removeAllWalls()
+		}
+	}
+	for _, f := range g.floors {
+		f.carve(lvl.Generator)
+	}
+	g.loops = 0
+	if lvl.Braid > 0 {
+		g.loops = g.braid(lvl.Braid)
+	}
+	g.visitTiles()
+	g.labelComponents()
+}
 
-func (g *Grid) carve() {
-	t := g.randomTile()
-	// t.recursiveBacktracker()
-	t.prim()
+// carve knocks down walls to make a maze, using Prim if the level doesn't specify a Generator
+func (g *Grid) carve(gen Generator) {
+	if gen == nil {
+		gen = Prim{}
+	}
+	gen.Generate(g, g.rng)
 }
 
-// func (g *Grid) fillCulDeSacs() {
-// 	for _, t := range g.tiles {
-// 		t.fillCulDeSac()
-// 	}
-// }
+// braid knocks through a wall of roughly factor (0.0 .. 1.0) of the dead ends,
+// turning a perfect maze (only one path between any two tiles) into one with loops,
+// so the ghosts have more ways to wander. It returns the number of loops added.
+func (g *Grid) braid(factor float64) int {
+	loops := 0
+	for _, i := range g.rng.Perm(len(g.tiles)) {
+		t := g.tiles[i]
+		// check again here, as knocking through an earlier dead end may have fixed this one
+		if !t.isCulDeSac() || g.rng.Float64() >= factor {
+			continue
+		}
+		// prefer to knock through into another dead end, as that removes two at once;
+		// never knock into a solid walled-in tile, as that would just make a new dead end
+		var dirs, culdesacs []Direction
+		for _, d := range ALL_DIRECTIONS {
+			tn := t.neighbour(d)
+			if tn == nil || !t.isWall(d) || tn.exits() == 0 {
+				continue
+			}
+			dirs = append(dirs, d)
+			if tn.isCulDeSac() {
+				culdesacs = append(culdesacs, d)
+			}
+		}
+		if len(culdesacs) > 0 {
+			dirs = culdesacs
+		}
+		if len(dirs) > 0 {
+			t.removeWall(dirs[g.rng.Intn(len(dirs))])
+			loops++
+		}
+	}
+	return loops
+}
 
-// AllTiles applies a func to all tiles
+// AllTiles applies a func to all tiles, including the tunnels under crossings
 func (g *Grid) AllTiles(fn func(*Tile)) {
 	for _, t := range g.tiles {
 		fn(t)
+		if t.under != nil {
+			fn(t.under)
+		}
 	}
 }
 
-// visitTiles rewalks the grid (from the middle) and resets the .visited
+// visitTiles rewalks the grid (from the pen) and resets the .visited
 // member, which is used to differentiate reachable and unreachable tiles;
 // .visited == true means tile is reachable
 func (g *Grid) visitTiles() {
-	g.AllTiles(func(t *Tile) { t.visited = false; t.parent = nil })
-	tFirst := g.findTile(TilesAcross/2, TilesDown/2)
+	g.AllTiles(func(t *Tile) { t.visited = false })
+	tFirst := TheTopology.Pen(g)[0]
 	q := []*Tile{tFirst}
-	tFirst.parent = tFirst
+	tFirst.visited = true
 	for len(q) > 0 {
 		t := q[0]
-		t.visited = true
 		q = q[1:] // take first tile off front of queue
 		for _, d := range ALL_DIRECTIONS {
 			if t.isWall(d) {
 				continue
 			}
+			// on a wrapped grid, the tiles on opposite edges are linked as neighbours,
+			// so there's still always a neighbour behind a missing wall
 			tn := t.neighbour(d)
 			if tn == nil {
 				log.Fatal("open unwalled edge found in visitTiles BFS")
 			}
-			if tn.parent == nil {
-				tn.parent = t
+			if !tn.visited {
+				tn.visited = true
 				q = append(q, tn)
 			}
 		}
+		if tn := t.stairs; tn != nil && !tn.visited {
+			tn.visited = true
+			q = append(q, tn)
+		}
 	}
 }
 
 // getMinimap shows position of ghosts
 func (g *Grid) getMinimap(screen *ebiten.Image) *ebiten.Image {
 
-	worldWidth, worldHeight := float64(TilesAcross*TileSize), float64(TilesDown*TileSize)
-	mapWidth, mapHeight := worldWidth/10, worldHeight/10
+	w, h := g.Size()
+	worldWidth, worldHeight := float64(w), float64(h)
+	mapWidth, floorHeight := worldWidth/10, worldHeight/10
+	mapHeight := floorHeight * float64(len(g.floors))
 
 	if g.ticks%10 != 0 && g.minimapImage != nil {
 		return g.minimapImage
@@ -360,32 +675,58 @@ func (g *Grid) getMinimap(screen *ebiten.Image) *ebiten.Image {
 
 	halfTileSize := float64(TileSize / 2)
 
+	// each floor gets a map of its own, stacked up with the ground floor at the bottom
+	floorY := func(floor int) float64 {
+		return float64(len(g.floors)-1-floor) * floorHeight
+	}
+
 	dc := gg.NewContext(int(mapWidth), int(mapHeight))
-	dc.DrawRectangle(0, 0, float64(mapWidth-1), float64(mapHeight-1))
-	dc.SetRGB(1, 1, 1)
-	dc.Stroke()
+	for i := range g.floors {
+		dc.Push()
+		dc.Translate(0, floorY(i))
+		if mo, ok := TheTopology.(minimapOutliner); ok {
+			mo.drawMinimapOutline(dc, mapWidth, floorHeight)
+		} else {
+			dc.DrawRectangle(0, 0, float64(mapWidth-1), float64(floorHeight-1))
+		}
+		dc.Pop()
+		if i == g.floor() {
+			dc.SetRGB(1, 1, 1)
+		} else {
+			dc.SetRGBA(1, 1, 1, 0.5)
+		}
+		dc.Stroke()
+	}
 
 	for _, gh := range g.ghosts {
-		x := util.MapValue(gh.worldX+halfTileSize, 0, worldWidth, 0, mapWidth)
-		y := util.MapValue(gh.worldY+halfTileSize, 0, worldHeight, 0, mapHeight)
-		dc.DrawCircle(x, y, 1)
+		x := util.MapValue(wrapInside(gh.worldX+halfTileSize, wrapWidth), 0, worldWidth, 0, mapWidth)
+		y := util.MapValue(wrapInside(gh.worldY+halfTileSize, wrapHeight), 0, worldHeight, 0, floorHeight)
+		dc.DrawCircle(x, y+floorY(gh.tile.floor), 1)
 	}
 	dc.SetRGB(1, 1, 1)
 	dc.Fill()
 
 	for _, m := range g.meanies {
-		x := util.MapValue(m.worldX+halfTileSize, 0, worldWidth, 0, mapWidth)
-		y := util.MapValue(m.worldY+halfTileSize, 0, worldHeight, 0, mapHeight)
-		dc.DrawCircle(x, y, 1)
+		x := util.MapValue(wrapInside(m.worldX+halfTileSize, wrapWidth), 0, worldWidth, 0, mapWidth)
+		y := util.MapValue(wrapInside(m.worldY+halfTileSize, wrapHeight), 0, worldHeight, 0, floorHeight)
+		dc.DrawCircle(x, y+floorY(m.tile.floor), 1)
 	}
 	dc.SetRGB(0, 0, 0)
 	dc.Fill()
 
-	{
-		x := util.MapValue(g.puck.worldX+halfTileSize, 0, worldWidth, 0, mapWidth)
-		y := util.MapValue(g.puck.worldY+halfTileSize, 0, worldHeight, 0, mapHeight)
-		dc.DrawCircle(x, y, 2)
-		dc.SetRGB(1, 1, 0)
+	for _, b := range g.blocks {
+		x := util.MapValue(wrapInside(b.worldX+halfTileSize, wrapWidth), 0, worldWidth, 0, mapWidth)
+		y := util.MapValue(wrapInside(b.worldY+halfTileSize, wrapHeight), 0, worldHeight, 0, floorHeight)
+		dc.DrawRectangle(x-1.5, y+floorY(b.tile.floor)-1.5, 3, 3)
+	}
+	dc.SetRGB(0.55, 0.45, 0.35)
+	dc.Fill()
+
+	for _, p := range g.pucks {
+		x := util.MapValue(wrapInside(p.worldX+halfTileSize, wrapWidth), 0, worldWidth, 0, mapWidth)
+		y := util.MapValue(wrapInside(p.worldY+halfTileSize, wrapHeight), 0, worldHeight, 0, floorHeight)
+		dc.DrawCircle(x, y+floorY(p.tile.floor), 2)
+		dc.SetColor(p.col)
 		dc.Fill()
 	}
 
@@ -399,17 +740,36 @@ func (g *Grid) getMinimap(screen *ebiten.Image) *ebiten.Image {
 // Layout implements ebiten.Game's Layout.
 func (g *Grid) Layout(outsideWidth, outsideHeight int) (int, int) {
 
-	worldWidth := float64(TilesAcross * TileSize)
-	mapWidth := worldWidth / 10
+	worldWidth, _ := g.Size()
+	mapWidth := float64(worldWidth) / 10
 	g.minimapX, g.minimapY = float64(outsideWidth)-mapWidth, 0
 
-	for _, t := range g.tiles {
-		t.Layout()
-	}
+	g.undoButton.SetPosition(outsideWidth-170, outsideHeight-30)
+	g.redoButton.SetPosition(outsideWidth-60, outsideHeight-30)
+
 	// g.puck.Layout(outsideWidth, outsideHeight)
 	return outsideWidth, outsideHeight
 }
 
+// complete records the score for the level, unlocks the next one, saves how far the player has got
+// and then shows the score; it's the last thing a level does, so nothing can happen afterwards to undo it
+func (g *Grid) complete() {
+	score := g.scoring.Score(g.tally)
+	best := TheUserData.recordScore(g.level, score)
+	TheUserData.unlock(g.level + 1)
+	if g.level+1 >= len(LevelData) {
+		// finishing the game starts it again, unless the last level was replayed from the level select menu
+		if g.level == TheUserData.CompletedLevels {
+			TheUserData.CompletedLevels = 0
+		}
+	} else {
+		// a level chosen from the level select menu may be one that was completed a while ago
+		TheUserData.CompletedLevels = max(TheUserData.CompletedLevels, g.level+1)
+	}
+	TheUserData.Save()
+	GSM.Switch(NewScoreCutscene(g.level, score, best))
+}
+
 // Update the board state (transitions, user input)
 func (g *Grid) Update() error {
 
@@ -420,6 +780,12 @@ func (g *Grid) Update() error {
 
 	g.input.Update()
 
+	g.tally.Ticks++
+
+	if g.refusedTicks > 0 {
+		g.refusedTicks--
+	}
+
 	for _, t := range g.tiles {
 		t.Update() // doesn't do anything at the moment
 	}
@@ -432,65 +798,206 @@ func (g *Grid) Update() error {
 		}
 	}
 	if count == len(g.ghosts) {
-		TheUserData.CompletedLevels++
-		if TheUserData.CompletedLevels >= len(LevelData) {
-			TheUserData.CompletedLevels = 0
-			TheUserData.Save()
-			GSM.Switch(NewGameover())
-		} else {
-			TheUserData.Save()
-			GSM.Switch(NewCutscene())
-		}
+		g.complete()
+		return nil
 	}
 	for _, m := range g.meanies {
 		m.Update()
+		if m.dest == nil && g.puckAt(m.tile) != nil {
+			// caught a puck; play this level again
+			GSM.Switch(NewLevelCutscene(g.level))
+			return nil
+		}
 	}
 
-	g.puck.Update()
+	for _, p := range g.pucks {
+		p.Update()
+	}
+
+	for _, b := range g.blocks {
+		b.Update()
+	}
+
+	if g.wallMenu != nil {
+		// the menu only makes sense while the puck it's on stays put
+		if p := g.activePuck; p != g.wallMenu.p || p.dest != nil || p.dozing {
+			g.closeWallMenu()
+		} else {
+			g.placeWallMenu()
+			g.wallMenu.Update()
+		}
+	}
 
 	return nil
 }
 
-// Draw renders the grid into the gridImage
-func (g *Grid) Draw(screen *ebiten.Image) {
+// floor returns the floor being shown, which is the one the puck is on
+func (g *Grid) floor() int {
+	return g.activePuck.tile.floor
+}
 
-	screen.Fill(g.colorBackground)
+// drawWorld renders the pen, tiles, blocks, ghosts, meanies and pucks on the floor being shown, offset by the camera
+func (g *Grid) drawWorld(screen *ebiten.Image) {
+	floor := g.floor()
 
-	{
+	if floor == 0 {
 		op := &ebiten.DrawImageOptions{}
 		op.GeoM.Translate(g.penX+CameraX, g.penY+CameraY)
 		// op.GeoM.Translate(CameraX, CameraY)
 		screen.DrawImage(g.penImage, op)
 	}
 
-	for _, t := range g.tiles {
+	tiles := g.floors[floor].tiles
+
+	// anything in a tunnel is drawn first, so it goes under the crossing above
+	for _, gh := range g.ghosts {
+		if gh.tile.floor == floor && gh.tile.over != nil {
+			gh.Draw(screen)
+		}
+	}
+
+	for _, m := range g.meanies {
+		if m.tile.floor == floor && m.tile.over != nil {
+			m.Draw(screen)
+		}
+	}
+
+	for _, b := range g.blocks {
+		if b.tile.floor == floor && b.tile.over != nil {
+			b.Draw(screen)
+		}
+	}
+
+	for _, t := range tiles {
+		t.DrawTerrain(screen)
+	}
+
+	for _, t := range tiles {
 		t.Draw(screen)
 	}
 
-	for _, t := range g.tiles {
+	for _, t := range tiles {
+		t.DrawStairs(screen)
+	}
+
+	for _, t := range tiles {
 		t.DrawMarked(screen)
 	}
 
+	g.drawDragPath(screen, floor)
+
+	for _, b := range g.blocks {
+		if b.tile.floor == floor && b.tile.over == nil {
+			b.Draw(screen)
+		}
+	}
+
 	for _, gh := range g.ghosts {
-		gh.Draw(screen)
+		if gh.tile.floor == floor && gh.tile.over == nil {
+			gh.Draw(screen)
+		}
 	}
 
 	for _, m := range g.meanies {
-		m.Draw(screen)
+		if m.tile.floor == floor && m.tile.over == nil {
+			m.Draw(screen)
+		}
 	}
 
-	g.puck.Draw(screen)
+	for _, p := range g.pucks {
+		if p.tile.floor == floor {
+			p.Draw(screen)
+		}
+	}
+}
+
+// drawDragPath shows the way a puck being dragged would go, with bigger dots than a marked path
+func (g *Grid) drawDragPath(screen *ebiten.Image, floor int) {
+	if g.dragPuck == nil {
+		return
+	}
+	col := g.dragPuck.col
+	for _, t := range g.dragPath {
+		if t.floor != floor || t == g.dragPuck.tile {
+			continue
+		}
+		op := &ebiten.DrawImageOptions{}
+		mid := float64(dotImage.Bounds().Dx() / 2)
+		op.GeoM.Translate(-mid, -mid)
+		op.GeoM.Scale(3, 3)
+		op.GeoM.Translate(mid, mid)
+		t.centerImage(op, dotImage)
+		op.GeoM.Translate(CameraX, CameraY)
+		op.ColorM.Translate(float64(col.R)/0xff, float64(col.G)/0xff, float64(col.B)/0xff, 0)
+		screen.DrawImage(dotImage, op)
+	}
+}
+
+// Draw renders the grid into the gridImage
+func (g *Grid) Draw(screen *ebiten.Image) {
+
+	screen.Fill(g.colorBackground)
+
+	if g.wrap {
+		// draw the world again all around itself, so the seams can't be seen
+		cameraX, cameraY := CameraX, CameraY
+		for _, dy := range []float64{-wrapHeight, 0, wrapHeight} {
+			for _, dx := range []float64{-wrapWidth, 0, wrapWidth} {
+				CameraX, CameraY = cameraX+dx, cameraY+dy
+				g.drawWorld(screen)
+			}
+		}
+		CameraX, CameraY = cameraX, cameraY
+	} else {
+		g.drawWorld(screen)
+	}
+
+	if g.wallMenu != nil {
+		g.wallMenu.Draw(screen)
+	}
 
 	{
 		op := &ebiten.DrawImageOptions{}
 		op.GeoM.Translate(g.minimapX, g.minimapY)
-		screen.DrawImage(g.getMinimap(screen), op)
+		minimap := g.getMinimap(screen)
+		screen.DrawImage(minimap, op)
+
+		if len(g.floors) > 1 {
+			str := fmt.Sprintf("Floor %d of %d", g.floor()+1, len(g.floors))
+			bound, _ := font.BoundString(TheAcmeFonts.small, str)
+			x := int(g.minimapX) - (bound.Max.X - bound.Min.X).Ceil() - 8
+			y := int(g.minimapY) + minimap.Bounds().Dy()
+			text.Draw(screen, str, TheAcmeFonts.small, x, y, BasicColors["White"])
+		}
+	}
+
+	{
+		// the number of walls the active puck is carrying, and how many more its bulldozer can knock down,
+		// in its colour, or red when there weren't any walls to place
+		str := fmt.Sprintf("Walls %d  Fuel %d", g.activePuck.walls, g.activePuck.fuel)
+		var col color.Color = g.activePuck.col
+		if g.refusedTicks > 0 {
+			str = fmt.Sprintf("Walls %d - none to place", g.activePuck.walls)
+			if (g.refusedTicks/8)%2 == 0 {
+				col = BasicColors["Red"]
+			} else {
+				col = BasicColors["White"]
+			}
+		}
+		text.Draw(screen, str, TheAcmeFonts.normal, 8, WindowHeight-8, col)
 	}
 
+	g.undoButton.Draw(screen)
+	g.redoButton.Draw(screen)
+
 	if DebugMode {
 		var ms runtime.MemStats
 		runtime.ReadMemStats(&ms)
-		ebitenutil.DebugPrint(screen, fmt.Sprintf("NumGC %v", ms.NumGC))
+		str := fmt.Sprintf("NumGC %v seed %v loops %v\nattempts %v %v", ms.NumGC, g.seed, g.loops, g.attempts, g.report.String())
+		if g.outOfBand {
+			str += "\nOUTSIDE DIFFICULTY BAND"
+		}
+		ebitenutil.DebugPrint(screen, str)
 		// ebitenutil.DebugPrint(screen, fmt.Sprintf("TPS %v, FPS %v, grid %d,%d camera %v,%v puck %v,%v",
 		// 	math.Ceil(ebiten.CurrentTPS()),
 		// 	math.Ceil(ebiten.CurrentFPS()),
@@ -0,0 +1,29 @@
+package maze
+
+import "testing"
+
+// TestCompleteWithMeanieOnPuck herds every ghost into the pen while a meanie sits on the puck;
+// finishing the level has to win, rather than the meanie sending the player back to play it again
+func TestCompleteWithMeanieOnPuck(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // where Save writes the user data
+	TheUserData = &UserData{}
+	TheGrid = NewGrid(0, 1)
+	g := TheGrid
+	GSM.Switch(g)
+
+	pen := TheTopology.Pen(g)
+	for i, gh := range g.ghosts {
+		gh.tile, gh.dest = pen[i%len(pen)], nil
+	}
+	g.meanies = append(g.meanies, NewMeanie(g.activePuck.tile, g.rng))
+
+	g.Update()
+
+	cs, ok := GSM.Get().(*Cutscene)
+	if !ok || cs.level != 1 || len(cs.lines) == 0 {
+		t.Fatal("finishing level 1 didn't show its score on the way to level 2")
+	}
+	if TheUserData.CompletedLevels != 1 {
+		t.Fatalf("CompletedLevels is %d, not 1", TheUserData.CompletedLevels)
+	}
+}
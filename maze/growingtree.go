@@ -0,0 +1,39 @@
+package maze
+
+import (
+	"math/rand"
+)
+
+// GrowingTree carves a maze by growing from a list of active tiles.
+// Newest is the probability of growing from the most recently added tile,
+// otherwise a random active tile is picked; 1.0 behaves like RecursiveBacktracker,
+// 0.0 like Prim, and values in between mix the two textures.
+type GrowingTree struct {
+	Newest float64
+}
+
+// Generate implements Generator
+func (gt GrowingTree) Generate(g *Grid, rng *rand.Rand) {
+	t := g.tiles[rng.Intn(len(g.tiles))]
+	t.visited = true
+	active := []*Tile{t}
+	for len(active) > 0 {
+		var i int
+		if rng.Float64() < gt.Newest {
+			i = len(active) - 1
+		} else {
+			i = rng.Intn(len(active))
+		}
+		t := active[i]
+		d := randomNeighbour(t, rng, isUnvisited)
+		if d == -1 {
+			// this tile is finished with, so keep the order but drop it from the list
+			active = append(active[:i], active[i+1:]...)
+			continue
+		}
+		t.removeWall(d)
+		tn := t.neighbour(d)
+		tn.visited = true
+		active = append(active, tn)
+	}
+}
@@ -0,0 +1,137 @@
+package maze
+
+import (
+	"image"
+	"log"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// hexagonal tile directions, clockwise from east
+const (
+	HEX_EAST = iota
+	HEX_SOUTHEAST
+	HEX_SOUTHWEST
+	HEX_WEST
+	HEX_NORTHWEST
+	HEX_NORTHEAST
+)
+
+var (
+	// hexWidth is the distance between the flat sides of a hex that's TileSize from point to point
+	hexWidth = float64(TileSize) * math.Sqrt(3) / 2
+	// hexRowStep is the distance between the centers of hexes in adjacent rows, which overlap
+	hexRowStep = float64(TileSize) * 3 / 4
+	// hexImages caches tile images, keyed by walls, plus 1<<6 if unreachable
+	hexImages = map[uint]*ebiten.Image{}
+)
+
+// HexTopology is a grid of pointy-topped hexagonal tiles with six walls each.
+// Rows are offset, with odd rows shoved right by half a tile, so tiles
+// still have X,Y coords and findTile works.
+type HexTopology struct{}
+
+// Directions implements Topology
+func (HexTopology) Directions() int {
+	return 6
+}
+
+// hexCenter returns the world coords of the middle of hex x,y
+func hexCenter(x, y int) (float64, float64) {
+	cx := hexWidth * (float64(x) + 0.5)
+	if y%2 == 1 {
+		cx += hexWidth / 2
+	}
+	cy := hexRowStep*float64(y) + float64(TileSize)/2
+	return cx, cy
+}
+
+// Link implements Topology
+func (HexTopology) Link(g *Grid) {
+	if g.wrap && TilesDown%2 == 1 {
+		// otherwise the top and bottom rows would both be offset the same way
+		log.Fatal("a wrapped hex grid needs an even number of rows")
+	}
+	g.addRows(func(t *Tile) {
+		cx, cy := hexCenter(t.X, t.Y)
+		t.worldX = cx - TileSize/2
+		t.worldY = cy - TileSize/2
+	})
+
+	for _, t := range g.tiles {
+		x := t.X
+		y := t.Y
+		// odd rows are offset to the right, so the diagonal neighbours are too
+		odd := y % 2
+		t.edges[HEX_EAST] = g.findTile(x+1, y)
+		t.edges[HEX_SOUTHEAST] = g.findTile(x+odd, y+1)
+		t.edges[HEX_SOUTHWEST] = g.findTile(x+odd-1, y+1)
+		t.edges[HEX_WEST] = g.findTile(x-1, y)
+		t.edges[HEX_NORTHWEST] = g.findTile(x+odd-1, y-1)
+		t.edges[HEX_NORTHEAST] = g.findTile(x+odd, y-1)
+	}
+}
+
+// Pen implements Topology; the pen is the middle hex and the six around it
+func (HexTopology) Pen(g *Grid) []*Tile {
+	mid := g.findTile(TilesAcross/2, TilesDown/2)
+	pen := []*Tile{mid}
+	for _, tn := range mid.edges {
+		if tn != nil {
+			pen = append(pen, tn)
+		}
+	}
+	return pen
+}
+
+// WallAngle implements Topology
+func (HexTopology) WallAngle(t *Tile, d Direction) float64 {
+	return float64(d) * math.Pi / 3
+}
+
+// hexCorners returns the corners of a hex centered on cx,cy, so that wall d
+// runs from corner d to corner d+1
+func hexCorners(cx, cy float64) [6][2]float64 {
+	var corners [6][2]float64
+	r := float64(TileSize) / 2
+	for i := range corners {
+		a := float64(i)*math.Pi/3 - math.Pi/6
+		corners[i] = [2]float64{cx + r*math.Cos(a), cy + r*math.Sin(a)}
+	}
+	return corners
+}
+
+// Outline implements Topology
+func (HexTopology) Outline(t *Tile) []image.Point {
+	cx, cy := t.center()
+	var pts []image.Point
+	for _, c := range hexCorners(cx, cy) {
+		pts = append(pts, image.Point{X: int(math.Round(c[0])), Y: int(math.Round(c[1]))})
+	}
+	return pts
+}
+
+// TileImage implements Topology
+func (HexTopology) TileImage(t *Tile) *ebiten.Image {
+	key := t.walls
+	if !t.visited {
+		key |= 1 << 6
+	}
+	img, ok := hexImages[key]
+	if !ok {
+		img = ebiten.NewImageFromImage(makeHexTileImage(t.walls, !t.visited))
+		hexImages[key] = img
+	}
+	return img
+}
+
+// Size implements Topology
+func (HexTopology) Size() (int, int) {
+	w := hexWidth * float64(TilesAcross)
+	if TilesDown > 1 {
+		w += hexWidth / 2
+	}
+	h := hexRowStep*float64(TilesDown-1) + float64(TileSize)
+	return int(math.Ceil(w)), int(math.Ceil(h))
+}
@@ -0,0 +1,170 @@
+package maze
+
+// maxHistorySteps is how many steps back can be undone
+const maxHistorySteps = 100
+
+// change is something that happened to the grid, which can be taken back and done again
+type change interface {
+	undo()
+	redo()
+}
+
+// wallChange is a wall being picked up, put down or bulldozed by a puck,
+// along with what that did to the puck's walls and fuel
+type wallChange struct {
+	p           *Puck
+	t           *Tile
+	d           Direction
+	walls, fuel int // how much the puck's walls and fuel went up by
+}
+
+func (c wallChange) undo() {
+	TheGrid.toggleWall(c.t, c.d)
+	c.p.walls -= c.walls
+	c.p.fuel -= c.fuel
+}
+
+func (c wallChange) redo() {
+	TheGrid.toggleWall(c.t, c.d)
+	c.p.walls += c.walls
+	c.p.fuel += c.fuel
+}
+
+// puckMove is a puck going from one tile to the next
+type puckMove struct {
+	p        *Puck
+	from, to *Tile
+}
+
+func (c puckMove) undo() { c.p.place(c.from) }
+func (c puckMove) redo() { c.p.place(c.to) }
+
+// blockMove is a block being pushed from one tile to the next
+type blockMove struct {
+	b        *Block
+	from, to *Tile
+}
+
+func (c blockMove) undo() { c.b.place(c.from) }
+func (c blockMove) redo() { c.b.place(c.to) }
+
+// step is everything that happened after the player did something, up until they did something else
+type step struct {
+	changes []change
+	before  []Ghost // the ghosts as they were when the step began
+	after   []Ghost // the ghosts as they were when the step was undone
+}
+
+// history is the steps that can be undone, and the steps that have been undone and can be redone.
+// Whether the ghosts go back too is up to RewindGhosts.
+type history struct {
+	done, undone []*step
+}
+
+// begin starts a new step, because the player has done something, with the ghosts as they are now;
+// anything undone can't be redone after this
+func (h *history) begin(ghosts []Ghost) {
+	h.undone = nil
+	if n := len(h.done); n > 0 && len(h.done[n-1].changes) == 0 {
+		// nothing came of the last thing the player did, so there's nothing to undo
+		h.done[n-1].before = ghosts
+		return
+	}
+	h.done = append(h.done, &step{before: ghosts})
+	if len(h.done) > maxHistorySteps {
+		h.done = h.done[1:]
+	}
+}
+
+// record adds a change to the latest step
+func (h *history) record(c change) {
+	if len(h.done) == 0 {
+		h.begin(nil)
+	}
+	s := h.done[len(h.done)-1]
+	s.changes = append(s.changes, c)
+}
+
+// Undo takes back the latest step, returning false if there wasn't one
+func (g *Grid) Undo() bool {
+	h := &g.history
+	if len(h.done) == 0 {
+		return false
+	}
+	g.halt()
+	s := h.done[len(h.done)-1]
+	h.done = h.done[:len(h.done)-1]
+	for i := len(s.changes) - 1; i >= 0; i-- {
+		s.changes[i].undo()
+	}
+	if RewindGhosts && s.before != nil {
+		s.after = g.ghostStates()
+		g.setGhostStates(s.before)
+	}
+	h.undone = append(h.undone, s)
+	g.settle()
+	return true
+}
+
+// Redo does the latest undone step again, returning false if there wasn't one
+func (g *Grid) Redo() bool {
+	h := &g.history
+	if len(h.undone) == 0 {
+		return false
+	}
+	g.halt()
+	s := h.undone[len(h.undone)-1]
+	h.undone = h.undone[:len(h.undone)-1]
+	for _, c := range s.changes {
+		c.redo()
+	}
+	if RewindGhosts && s.after != nil {
+		g.setGhostStates(s.after)
+	}
+	h.done = append(h.done, s)
+	g.settle()
+	return true
+}
+
+// halt stops the pucks and blocks where they are, so changes can be taken back
+// or done again from tile to tile; a puck part way to a tile goes back,
+// a block part way there (which has already been recorded as pushed) goes on
+func (g *Grid) halt() {
+	g.dragPuck, g.dragPath = nil, nil
+	for _, p := range g.pucks {
+		p.dozing = false
+		p.clearPath()
+		p.place(p.tile)
+	}
+	for _, b := range g.blocks {
+		if b.dest != nil {
+			b.place(b.dest)
+		}
+	}
+}
+
+// settle puts each puck's ball down where the puck is, so it stays put after an undo or redo.
+// The walls have been kept track of by toggleWall, but the pucks and blocks may be elsewhere.
+func (g *Grid) settle() {
+	for _, p := range g.pucks {
+		p.ball.Drop(p.tile)
+	}
+	g.flow = nil
+	g.minimapImage = nil
+}
+
+// ghostStates returns a copy of every ghost, as it is now
+func (g *Grid) ghostStates() []Ghost {
+	states := make([]Ghost, len(g.ghosts))
+	for i, gh := range g.ghosts {
+		states[i] = *gh
+	}
+	return states
+}
+
+// setGhostStates puts every ghost back as it was in states
+func (g *Grid) setGhostStates(states []Ghost) {
+	for i, gh := range g.ghosts {
+		*gh = states[i]
+	}
+}
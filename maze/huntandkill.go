@@ -0,0 +1,45 @@
+package maze
+
+import (
+	"math/rand"
+)
+
+// HuntAndKill carves a maze with random walks; when a walk gets stuck,
+// it hunts for an unvisited tile next to the maze and starts again from there.
+// The result looks like RecursiveBacktracker, with long winding corridors.
+type HuntAndKill struct{}
+
+// hunt scans the grid for an unvisited tile that borders the maze,
+// joins it to the maze and returns it, or returns nil if there are none
+func (HuntAndKill) hunt(g *Grid, rng *rand.Rand) *Tile {
+	for _, t := range g.tiles {
+		if t.visited {
+			continue
+		}
+		if d := randomNeighbour(t, rng, isVisited); d != -1 {
+			t.removeWall(d)
+			t.visited = true
+			return t
+		}
+	}
+	return nil
+}
+
+// Generate implements Generator
+func (hk HuntAndKill) Generate(g *Grid, rng *rand.Rand) {
+	t := g.tiles[rng.Intn(len(g.tiles))]
+	t.visited = true
+	for t != nil {
+		// kill: walk randomly until there's nowhere new to go
+		for {
+			d := randomNeighbour(t, rng, isUnvisited)
+			if d == -1 {
+				break
+			}
+			t.removeWall(d)
+			t = t.neighbour(d)
+			t.visited = true
+		}
+		t = hk.hunt(g, rng)
+	}
+}
@@ -105,6 +105,176 @@ func makeTileImage(walls uint, unreachable bool) image.Image {
 	return dc.Image()
 }
 
+// return an image.Image of a hex tile, bigger than the tile so endcaps are visible
+func makeHexTileImage(walls uint, unreachable bool) image.Image {
+
+	tileSizeEx := TileSize + (TileSize / 6) // same as linewidth
+	lineWidth := float64(TileSize / 6)
+	mid := float64(tileSizeEx) / 2
+	corners := hexCorners(mid, mid)
+
+	dc := gg.NewContext(tileSizeEx, tileSizeEx)
+
+	if !unreachable {
+		dc.SetRGBA(0, 0, 0, 0.2)
+		for _, c := range corners {
+			dc.LineTo(c[0], c[1])
+		}
+		dc.ClosePath()
+		dc.Fill()
+		dc.Stroke()
+	}
+
+	dc.SetRGBA(0, 0, 0, 1) // black walls, get recolored when drawn
+	dc.SetLineWidth(lineWidth)
+	dc.SetLineCap(gg.LineCapRound)
+
+	if walls == 0b111111 {
+		for _, c := range corners {
+			dc.LineTo(c[0], c[1])
+		}
+		dc.ClosePath()
+	} else {
+		// start just after a gap, so that runs of walls are drawn as joined-up lines
+		start := 0
+		for walls&(1<<start) != 0 {
+			start++
+		}
+		drawing := false
+		for i := 1; i <= 6; i++ {
+			d := (start + i) % 6
+			if walls&(1<<d) == 0 {
+				drawing = false
+				continue
+			}
+			if !drawing {
+				dc.MoveTo(corners[d][0], corners[d][1])
+				drawing = true
+			}
+			c := corners[(d+1)%6]
+			dc.LineTo(c[0], c[1])
+		}
+	}
+	dc.Stroke()
+
+	return dc.Image()
+}
+
+// makeCrossingTileImage draws a square tile with a tunnel under it, as a tile with the tunnel's
+// walls showing faintly through the floor
+func makeCrossingTileImage(walls uint, tunnelEastWest bool, unreachable bool) image.Image {
+
+	tileSizeEx := TileSize + (TileSize / 6) // same as linewidth
+	margin := float64(tileSizeEx-TileSize) / 2
+	lineWidth := float64(TileSize / 6)
+
+	dc := gg.NewContextForImage(makeTileImage(walls, unreachable))
+	dc.SetRGBA(0, 0, 0, 0.25)
+	dc.SetLineWidth(lineWidth / 2)
+	dc.SetDash(lineWidth/2, lineWidth/2)
+
+	n, w := margin+lineWidth, margin+lineWidth
+	e, s := float64(tileSizeEx)-margin-lineWidth, float64(tileSizeEx)-margin-lineWidth
+	if tunnelEastWest {
+		dc.DrawLine(margin, n, float64(tileSizeEx)-margin, n)
+		dc.DrawLine(margin, s, float64(tileSizeEx)-margin, s)
+	} else {
+		dc.DrawLine(w, margin, w, float64(tileSizeEx)-margin)
+		dc.DrawLine(e, margin, e, float64(tileSizeEx)-margin)
+	}
+	dc.Stroke()
+
+	return dc.Image()
+}
+
+// makeStairsImage draws a chevron pointing up or down, for a tile with stairs to the floor above or below
+func makeStairsImage(up bool) image.Image {
+	dc := gg.NewContext(TileSize, TileSize)
+	mid := float64(TileSize / 2)
+	arm := float64(TileSize / 4)
+	tip, base := mid-arm/2, mid+arm/2
+	if !up {
+		tip, base = base, tip
+	}
+	dc.SetRGBA(0, 0, 0, 0.5)
+	dc.SetLineWidth(float64(TileSize / 12))
+	dc.SetLineCap(gg.LineCapRound)
+	dc.SetLineJoin(gg.LineJoinRound)
+	for _, dy := range []float64{-arm / 3, arm / 3} {
+		dc.MoveTo(mid-arm, base+dy)
+		dc.LineTo(mid, tip+dy)
+		dc.LineTo(mid+arm, base+dy)
+		dc.NewSubPath()
+	}
+	dc.Stroke()
+	return dc.Image()
+}
+
+// makePolarTileImage draws a polar tile, which is big enough to hold the tile whatever ring it's in
+func makePolarTileImage(t *Tile) image.Image {
+
+	lineWidth := float64(TileSize / 6)
+	size := TileSize*2 + TileSize/6
+	cx, cy := t.center()
+	// offset from world coords to image coords, matching Tile.centerImage
+	ox, oy := cx-float64(size/2), cy-float64(size/2)
+
+	dc := gg.NewContext(size, size)
+
+	if t.visited {
+		dc.SetRGBA(0, 0, 0, 0.2)
+		for _, p := range polarOutline(t) {
+			dc.LineTo(p[0]-ox, p[1]-oy)
+		}
+		dc.ClosePath()
+		dc.Fill()
+	}
+
+	dc.SetRGBA(0, 0, 0, 1) // black walls, get recolored when drawn
+	dc.SetLineWidth(lineWidth)
+	dc.SetLineCap(gg.LineCapRound)
+
+	rIn, rOut, theta0, theta1 := polarBounds(t)
+	thetaMid := (theta0 + theta1) / 2
+	mx, my := polarPoint(0, 0)
+	mx, my = mx-ox, my-oy
+
+	arc := func(radius, a0, a1 float64) {
+		dc.NewSubPath()
+		dc.DrawArc(mx, my, radius, a0, a1)
+	}
+	spoke := func(theta float64) {
+		x0, y0 := polarPoint(rIn, theta)
+		x1, y1 := polarPoint(rOut, theta)
+		dc.NewSubPath()
+		dc.MoveTo(x0-ox, y0-oy)
+		dc.LineTo(x1-ox, y1-oy)
+	}
+
+	if rIn > 0 && t.isWall(POLAR_IN) {
+		arc(rIn, theta0, theta1)
+	}
+	if t.isWall(POLAR_CW) {
+		spoke(theta1)
+	}
+	if t.isWall(POLAR_CCW) {
+		spoke(theta0)
+	}
+	if polarSplit(t) {
+		if t.isWall(POLAR_OUT) {
+			arc(rOut, theta0, thetaMid)
+		}
+		if t.isWall(POLAR_OUT2) {
+			arc(rOut, thetaMid, theta1)
+		}
+	} else if t.isWall(POLAR_OUT) {
+		arc(rOut, theta0, theta1)
+	}
+	dc.Stroke()
+
+	return dc.Image()
+}
+
 var polyCoords = []float64{
 	-12, 10, // bottom left
 	-8, 8,
@@ -0,0 +1,83 @@
+package maze
+
+import (
+	"log"
+	"math/rand"
+)
+
+// Kruskal carves a maze using a randomized version of Kruskal's algorithm,
+// knocking down walls in random order whenever they separate two unconnected regions.
+// The result has lots of short, evenly spread dead ends.
+type Kruskal struct {
+	Weave float64 // chance (0.0 .. 1.0) of each tile becoming a crossing, with a tunnel underneath; square tiles only
+}
+
+// Generate implements Generator
+func (k Kruskal) Generate(g *Grid, rng *rand.Rand) {
+	ds := disjointSet{}
+	for _, t := range g.tiles {
+		for _, d := range ALL_DIRECTIONS {
+			if tn := t.neighbour(d); tn != nil && !t.isWall(d) {
+				// already open (the pen), so already connected
+				ds.union(t, tn)
+			}
+		}
+	}
+	if k.Weave > 0 {
+		k.weave(g, rng, ds)
+	}
+
+	type wall struct {
+		t *Tile
+		d Direction
+	}
+	var walls []wall
+	for _, t := range g.tiles {
+		for _, d := range ALL_DIRECTIONS {
+			if tn := t.neighbour(d); tn != nil && t.isWall(d) {
+				walls = append(walls, wall{t: t, d: d})
+			}
+		}
+	}
+	rng.Shuffle(len(walls), func(i, j int) { walls[i], walls[j] = walls[j], walls[i] })
+	for _, w := range walls {
+		if ds.union(w.t, w.t.neighbour(w.d)) {
+			w.t.removeWall(w.d)
+		}
+	}
+}
+
+// weave turns some tiles into crossings before any other walls are knocked down,
+// with a straight passage over the top and a tunnel underneath at right angles to it.
+// Both are joined up in ds, so the rest of the carving can't make loops through them.
+func (k Kruskal) weave(g *Grid, rng *rand.Rand, ds disjointSet) {
+	if _, ok := TheTopology.(SquareTopology); !ok {
+		log.Fatal("Kruskal can only weave square tiles")
+	}
+	for _, i := range rng.Perm(len(g.tiles)) {
+		t := g.tiles[i]
+		if rng.Float64() >= k.Weave || !t.canCross() {
+			continue
+		}
+		over, under := Direction(NORTH), Direction(EAST)
+		if rng.Intn(2) == 0 {
+			over, under = under, over
+		}
+		o1, o2 := t.neighbour(over), t.neighbour(over+2)
+		if ds.find(t) == ds.find(o1) || ds.find(t) == ds.find(o2) || ds.find(o1) == ds.find(o2) {
+			continue
+		}
+		t.removeWall(over)
+		t.removeWall(over + 2)
+		ds.union(t, o1)
+		ds.union(t, o2)
+		// the tunnel would make a loop if the passage over the top has already joined its ends,
+		// in which case t is just a straight corridor
+		u1, u2 := t.neighbour(under), t.neighbour(under+2)
+		if ds.find(u1) != ds.find(u2) {
+			tunnel := t.addTunnel(under)
+			ds.union(u1, tunnel)
+			ds.union(tunnel, u2)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package maze
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const (
+	levelButtonWidth  = 120
+	levelButtonHeight = 80
+	levelButtonGap    = 40 // room under each button for its best score
+	levelSelectTop    = 120
+)
+
+// LevelSelect represents a game state, a grid of buttons (one for each level) that scrolls up and down.
+// Levels that haven't been unlocked yet are greyed out, the others show their best score.
+type LevelSelect struct {
+	input         *Input
+	buttonInput   *Input // the buttons only hear about taps that LevelSelect passes on to them
+	title         *Label
+	back          *TextButton
+	buttons       []*TextButton
+	scores        []*Label // under each button, nil for levels that are locked
+	scrollY       int      // how far the grid of buttons has been scrolled up, in pixels
+	maxY          int      // how far it can be scrolled
+	pressY        int      // where the pointer went down, to scroll by dragging
+	pressScrollY  int      // how far the grid had been scrolled when the pointer went down
+	width, height int      // of the screen, from Layout
+}
+
+// NewLevelSelect creates and initializes a LevelSelect/GameState object
+func NewLevelSelect() *LevelSelect {
+	i := NewInput()
+	ls := &LevelSelect{input: i, buttonInput: NewInput()}
+	i.Add(ls)
+
+	ls.title = NewLabel("CHOOSE A LEVEL", TheAcmeFonts.large)
+	ls.back = NewTextButton("BACK", 120, 50, TheAcmeFonts.normal, func() { GSM.Switch(NewMenu()) }, ls.buttonInput)
+
+	for level := range LevelData {
+		level := level // golang gotcha: each func needs its own copy of level
+		var tb *TextButton
+		tb = NewTextButton(fmt.Sprint(level+1), levelButtonWidth, levelButtonHeight, TheAcmeFonts.large, func() {
+			// buttons scrolled up under the title can't be seen, so can't be tapped
+			if _, y0, _, _ := tb.Rect(); y0 >= levelSelectTop-levelButtonGap/2 {
+				GSM.Switch(NewLevelCutscene(level))
+			}
+		}, ls.buttonInput)
+		var score *Label
+		if !TheUserData.unlocked(level) {
+			tb.Disable()
+		} else if level < len(TheUserData.BestScores) && TheUserData.BestScores[level] > 0 {
+			score = NewLabel(fmt.Sprintf("Best %d", TheUserData.BestScores[level]), TheAcmeFonts.small)
+		} else {
+			score = NewLabel("Not scored", TheAcmeFonts.small)
+		}
+		ls.buttons = append(ls.buttons, tb)
+		ls.scores = append(ls.scores, score)
+	}
+
+	return ls
+}
+
+// NotifyCallback is called by the Subject (Input) when something interesting happens;
+// dragging scrolls the buttons, taps go to the buttons, and Backspace goes back to the menu
+func (ls *LevelSelect) NotifyCallback(event interface{}) {
+	switch v := event.(type) {
+	case PointerEvent:
+		switch v.Kind {
+		case PointerPress:
+			ls.pressY, ls.pressScrollY = v.Pt.Y, ls.scrollY
+		case PointerDrag:
+			ls.scrollTo(ls.pressScrollY - (v.Pt.Y - ls.pressY))
+		}
+	case image.Point:
+		// the buttons aren't observers of ls.input, as they'd hear about Backspace too
+		ls.buttonInput.Notify(v)
+	case ebiten.Key:
+		if v == ebiten.KeyBackspace {
+			GSM.Switch(NewMenu())
+		}
+	}
+}
+
+// scrollTo scrolls the buttons, but not so far that they all go off the screen
+func (ls *LevelSelect) scrollTo(y int) {
+	ls.scrollY = y
+	ls.place()
+}
+
+// Layout implements ebiten.Game's Layout
+func (ls *LevelSelect) Layout(outsideWidth, outsideHeight int) (int, int) {
+	ls.width, ls.height = outsideWidth, outsideHeight
+	ls.place()
+	return outsideWidth, outsideHeight
+}
+
+// place puts the title and buttons where they go on the screen, as it's scrolled now;
+// as many buttons go across the screen as will fit
+func (ls *LevelSelect) place() {
+	ls.title.SetPosition(ls.width/2, levelSelectTop/2)
+	ls.back.SetPosition(80, levelSelectTop/2)
+
+	cols := max(1, (ls.width-levelButtonGap)/(levelButtonWidth+levelButtonGap))
+	rows := (len(ls.buttons) + cols - 1) / cols
+	left := (ls.width - cols*(levelButtonWidth+levelButtonGap) + levelButtonGap) / 2
+	ls.maxY = max(0, levelSelectTop+rows*(levelButtonHeight+levelButtonGap)-ls.height)
+	ls.scrollY = max(0, min(ls.scrollY, ls.maxY))
+
+	for i, tb := range ls.buttons {
+		x := left + (i%cols)*(levelButtonWidth+levelButtonGap) + levelButtonWidth/2
+		y := levelSelectTop + (i/cols)*(levelButtonHeight+levelButtonGap) + levelButtonHeight/2 - ls.scrollY
+		tb.SetPosition(x, y)
+		if ls.scores[i] != nil {
+			ls.scores[i].SetPosition(x, y+levelButtonHeight/2+levelButtonGap/2)
+		}
+	}
+}
+
+// Update updates the current game state.
+func (ls *LevelSelect) Update() error {
+
+	ls.input.Update()
+
+	if _, dy := ebiten.Wheel(); dy != 0 {
+		ls.scrollTo(ls.scrollY - int(dy*levelButtonHeight/2))
+	}
+
+	return nil
+}
+
+// Draw draws the current GameState to the given screen
+func (ls *LevelSelect) Draw(screen *ebiten.Image) {
+	screen.Fill(colorBackground)
+
+	// the buttons scroll under the title and back button
+	grid := screen.SubImage(image.Rect(0, levelSelectTop-levelButtonGap/2, screen.Bounds().Dx(), screen.Bounds().Dy())).(*ebiten.Image)
+	for i, tb := range ls.buttons {
+		tb.Draw(grid)
+		if ls.scores[i] != nil {
+			ls.scores[i].Draw(grid)
+		}
+	}
+
+	ls.title.Draw(screen)
+	ls.back.Draw(screen)
+}
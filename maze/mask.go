@@ -0,0 +1,105 @@
+package maze
+
+import (
+	"bytes"
+	"image"
+	_ "image/png" // so image.Decode understands PNG masks
+	"log"
+)
+
+// Mask is the shape of a level: a grid of on/off cells, where only the cells
+// that are on become tiles. The grid takes its size from the mask.
+// Mask X,Y are tile coords, so shapes come out best on square tiles.
+type Mask struct {
+	on [][]bool // indexed [y][x]
+}
+
+// MaskFromASCII makes a mask from rows of text, where 'X' (or '#') is off and anything else is on
+func MaskFromASCII(rows ...string) *Mask {
+	m := &Mask{}
+	for _, row := range rows {
+		line := make([]bool, len(row))
+		for x, ch := range row {
+			line[x] = ch != 'X' && ch != '#'
+		}
+		m.on = append(m.on, line)
+	}
+	return m
+}
+
+// MaskFromPNG makes a mask from an image, one pixel per tile, where dark or transparent pixels are off
+func MaskFromPNG(data []byte) *Mask {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		log.Fatal(err)
+	}
+	b := img.Bounds()
+	m := &Mask{}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		line := make([]bool, b.Dx())
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, a := img.At(x, y).RGBA()
+			line[x-b.Min.X] = a > 0x8000 && r+g+bl > 0x18000
+		}
+		m.on = append(m.on, line)
+	}
+	return m
+}
+
+// Size returns the width and height of the mask, in tiles
+func (m *Mask) Size() (int, int) {
+	w := 0
+	for _, line := range m.on {
+		if len(line) > w {
+			w = len(line)
+		}
+	}
+	return w, len(m.on)
+}
+
+// On returns true if x,y should be a tile; anything outside the mask is off
+func (m *Mask) On(x, y int) bool {
+	if y < 0 || y >= len(m.on) || x < 0 || x >= len(m.on[y]) {
+		return false
+	}
+	return m.on[y][x]
+}
+
+// applyMask removes the tiles that the mask turns off, unlinking them from their neighbours.
+// Pen tiles are always kept, so the pen stays whole whatever the mask looks like,
+// and so are the tiles joined to the pen, so every tile left can be carved into the maze.
+func (g *Grid) applyMask(m *Mask, pen []*Tile) {
+	keep := map[*Tile]bool{}
+	for _, t := range pen {
+		keep[t] = true
+	}
+	// flood out from the pen through tiles that are on, so islands cut off by the mask are dropped too
+	q := append([]*Tile{}, pen...)
+	for len(q) > 0 {
+		t := q[0]
+		q = q[1:]
+		for _, tn := range t.edges {
+			if tn != nil && !keep[tn] && m.On(tn.X, tn.Y) {
+				keep[tn] = true
+				q = append(q, tn)
+			}
+		}
+	}
+
+	var tiles []*Tile
+	for _, t := range g.tiles {
+		if keep[t] {
+			tiles = append(tiles, t)
+			continue
+		}
+		g.rows[t.Y][t.X] = nil
+		for d, tn := range t.edges {
+			if tn != nil {
+				tn.edges[whichDirIs(tn, t)] = nil
+				t.edges[d] = nil
+			}
+		}
+	}
+	g.tiles = tiles
+	g.masked = true
+}
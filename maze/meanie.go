@@ -11,6 +11,7 @@ import (
 type Meanie struct {
 	tile                   *Tile   // tile we are sitting on
 	dest                   *Tile   // tile we are lerping to
+	prev                   *Tile   // tile we have just come from, so ice knows which way we were going
 	srcX, srcY, dstX, dstY float64 // positions for lerp
 	lerpstep               float64
 	speed                  float64
@@ -18,10 +19,10 @@ type Meanie struct {
 	img                    *ebiten.Image
 }
 
-func NewMeanie(start *Tile) *Meanie {
+func NewMeanie(start *Tile, rng *rand.Rand) *Meanie {
 	m := &Meanie{tile: start}
 	m.worldX, m.worldY = m.tile.position()
-	m.speed = 0.01 + (rand.Float64() * 0.01)
+	m.speed = 0.01 + (rng.Float64() * 0.01)
 	{
 		dc := gg.NewContext(TileSize, TileSize)
 		dc.SetColor(BasicColors["Black"])
@@ -35,21 +36,27 @@ func NewMeanie(start *Tile) *Meanie {
 
 func (m *Meanie) Update() error {
 	if m.dest == nil {
-		m.dest = TheGrid.findTileTowards(m.tile, TheGrid.puck.tile)
+		// the terrain may push the meanie somewhere, otherwise it heads for the puck
+		m.dest = m.tile.forcedMove(m.prev)
+		m.prev = nil
+		if m.dest == nil {
+			m.dest = TheGrid.flowToPuck().next(m.tile)
+		}
 		if m.dest != nil {
 			m.lerpstep = 0
 			m.srcX, m.srcY = m.tile.position()
-			m.dstX, m.dstY = m.dest.position()
+			m.dstX, m.dstY = m.dest.positionNear(m.srcX, m.srcY)
 		}
 	} else {
 		if m.lerpstep >= 1 {
+			m.prev = m.tile
 			m.tile = m.dest
 			m.worldX, m.worldY = m.tile.position()
 			m.dest = nil
 		} else {
 			m.worldX = util.Lerp(m.srcX, m.dstX, m.lerpstep)
 			m.worldY = util.Lerp(m.srcY, m.dstY, m.lerpstep)
-			m.lerpstep += m.speed
+			m.lerpstep += m.speed * m.dest.terrain.speed()
 		}
 	}
 	return nil
@@ -33,6 +33,10 @@ func NewMenu() *Menu {
 		)
 	}
 
+	s.widgets = append(s.widgets,
+		NewTextButton("LEVELS", 200, 50, TheAcmeFonts.normal, func() { GSM.Switch(NewLevelSelect()) }, i),
+	)
+
 	return s
 }
 
@@ -0,0 +1,210 @@
+package maze
+
+import (
+	"container/heap"
+	"math"
+)
+
+// The pathfinding functions here don't touch any tile state; each search keeps its own
+// bookkeeping, so the puck and any number of meanies can all plan routes in the same tick.
+
+// CostFunc returns the cost of stepping from a tile to one next to it; nil means every step costs 1.
+// Costs should be at least 1, or A* may not find the cheapest path; +Inf means the step can't be taken.
+type CostFunc func(from, to *Tile) float64
+
+// openNeighbours returns the tiles that can be reached in one step from t:
+// through a missing wall, or up or down stairs
+func (t *Tile) openNeighbours() []*Tile {
+	var tiles []*Tile
+	for _, d := range ALL_DIRECTIONS {
+		if tn := t.neighbour(d); tn != nil && !t.isWall(d) {
+			tiles = append(tiles, tn)
+		}
+	}
+	if t.stairs != nil {
+		tiles = append(tiles, t.stairs)
+	}
+	return tiles
+}
+
+// BFSDistances returns the number of steps from src to every tile that can be reached from it
+func (g *Grid) BFSDistances(src *Tile) map[*Tile]int {
+	dist := map[*Tile]int{src: 0}
+	q := []*Tile{src}
+	for len(q) > 0 {
+		t := q[0]
+		q = q[1:] // take first tile off front of queue
+		for _, tn := range t.openNeighbours() {
+			if _, ok := dist[tn]; !ok {
+				dist[tn] = dist[t] + 1
+				q = append(q, tn)
+			}
+		}
+	}
+	return dist
+}
+
+// BFSPath returns a shortest path from src to dst, starting with src and ending with dst,
+// or nil if dst can't be reached (if the puck has walled a ghost in, for example)
+func (g *Grid) BFSPath(src, dst *Tile) []*Tile {
+	parent := map[*Tile]*Tile{src: src}
+	q := []*Tile{src}
+	for len(q) > 0 {
+		t := q[0]
+		q = q[1:]
+		if t == dst {
+			return pathTo(parent, dst)
+		}
+		for _, tn := range t.openNeighbours() {
+			if _, ok := parent[tn]; !ok {
+				parent[tn] = t
+				q = append(q, tn)
+			}
+		}
+	}
+	return nil
+}
+
+// DijkstraDistances returns the cost of the cheapest way from src to every tile that can be reached from it
+func (g *Grid) DijkstraDistances(src *Tile, cost CostFunc) map[*Tile]float64 {
+	dist, _ := g.search([]*Tile{src}, nil, cost, nil)
+	return dist
+}
+
+// DijkstraDistancesTo returns the cost of the cheapest way to dst from every tile that can get there;
+// as costs may be different each way (going with a conveyor, say), it's not the same as DijkstraDistances(dst)
+func (g *Grid) DijkstraDistancesTo(dst *Tile, cost CostFunc) map[*Tile]float64 {
+	return g.DijkstraDistancesToAny([]*Tile{dst}, cost)
+}
+
+// DijkstraDistancesToAny returns the cost of the cheapest way to the nearest of dsts from every tile that can get to one
+func (g *Grid) DijkstraDistancesToAny(dsts []*Tile, cost CostFunc) map[*Tile]float64 {
+	if cost == nil {
+		cost = func(from, to *Tile) float64 { return 1 }
+	}
+	// search backwards from dsts, so each step is costed the way it would be taken
+	dist, _ := g.search(dsts, nil, func(from, to *Tile) float64 { return cost(to, from) }, nil)
+	return dist
+}
+
+// DijkstraPath returns the cheapest path from src to dst, starting with src and ending with dst,
+// or nil if dst can't be reached
+func (g *Grid) DijkstraPath(src, dst *Tile, cost CostFunc) []*Tile {
+	_, parent := g.search([]*Tile{src}, dst, cost, nil)
+	if _, ok := parent[dst]; !ok {
+		return nil
+	}
+	return pathTo(parent, dst)
+}
+
+// AStarPath is like DijkstraPath, but heads towards dst first, guided by the Manhattan distance
+func (g *Grid) AStarPath(src, dst *Tile, cost CostFunc) []*Tile {
+	_, parent := g.search([]*Tile{src}, dst, cost, g.manhattan)
+	if _, ok := parent[dst]; !ok {
+		return nil
+	}
+	return pathTo(parent, dst)
+}
+
+// manhattan estimates the number of steps between two tiles, allowing for wrapping and stairs.
+// On anything other than square tiles, X,Y don't measure distance, so it returns 0
+// and A* becomes Dijkstra.
+func (g *Grid) manhattan(a, b *Tile) float64 {
+	if _, ok := TheTopology.(SquareTopology); !ok {
+		return 0
+	}
+	abs := func(n int) int {
+		if n < 0 {
+			return -n
+		}
+		return n
+	}
+	dx, dy := abs(a.X-b.X), abs(a.Y-b.Y)
+	if g.wrap {
+		dx = min(dx, TilesAcross-dx)
+		dy = min(dy, TilesDown-dy)
+	}
+	return float64(dx + dy + abs(a.floor-b.floor))
+}
+
+// search is Dijkstra's algorithm, or A* if there's a heuristic, from the nearest of srcs until dst is reached
+// (or until everything has been reached if dst is nil). It returns the cost of getting to each
+// tile it finished with, and the tile before each one on the cheapest way there.
+func (g *Grid) search(srcs []*Tile, dst *Tile, cost CostFunc, heuristic func(a, b *Tile) float64) (map[*Tile]float64, map[*Tile]*Tile) {
+	if cost == nil {
+		cost = func(from, to *Tile) float64 { return 1 }
+	}
+	estimate := func(t *Tile) float64 {
+		if heuristic == nil || dst == nil {
+			return 0
+		}
+		return heuristic(t, dst)
+	}
+
+	dist := map[*Tile]float64{}
+	parent := map[*Tile]*Tile{}
+	done := map[*Tile]bool{}
+	q := &tileQueue{}
+	for _, src := range srcs {
+		dist[src] = 0
+		parent[src] = src
+		heap.Push(q, tileQueueItem{t: src, priority: estimate(src)})
+	}
+	for q.Len() > 0 {
+		t := heap.Pop(q).(tileQueueItem).t
+		if done[t] {
+			continue // a stale entry, from before a cheaper way here was found
+		}
+		done[t] = true
+		if t == dst {
+			break
+		}
+		for _, tn := range t.openNeighbours() {
+			c := cost(t, tn)
+			if math.IsInf(c, 1) {
+				continue
+			}
+			d := dist[t] + c
+			if old, ok := dist[tn]; !ok || d < old {
+				dist[tn] = d
+				parent[tn] = t
+				heap.Push(q, tileQueueItem{t: tn, priority: d + estimate(tn)})
+			}
+		}
+	}
+	return dist, parent
+}
+
+// pathTo follows parent links back from dst to the start of a search, returning the path the right way round
+func pathTo(parent map[*Tile]*Tile, dst *Tile) []*Tile {
+	var path []*Tile
+	for t := dst; ; t = parent[t] {
+		path = append(path, t)
+		if parent[t] == t {
+			break
+		}
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+type tileQueueItem struct {
+	t        *Tile
+	priority float64
+}
+
+// tileQueue is a priority queue of tiles, cheapest first, for use with container/heap
+type tileQueue []tileQueueItem
+
+func (q tileQueue) Len() int            { return len(q) }
+func (q tileQueue) Less(i, j int) bool  { return q[i].priority < q[j].priority }
+func (q tileQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *tileQueue) Push(x interface{}) { *q = append(*q, x.(tileQueueItem)) }
+func (q *tileQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
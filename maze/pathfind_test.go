@@ -0,0 +1,211 @@
+package maze
+
+import (
+	"math"
+	"testing"
+)
+
+// openGrid hand-builds a grid of w by h square tiles on each of floors floors, with no walls at all
+func openGrid(t *testing.T, w, h, floors int, wrap bool) *Grid {
+	topology, across, down := TheTopology, TilesAcross, TilesDown
+	t.Cleanup(func() { TheTopology, TilesAcross, TilesDown = topology, across, down })
+
+	TheTopology = SquareTopology{}
+	TilesAcross, TilesDown = w, h
+	g := &Grid{wrap: wrap}
+	for i := 0; i < floors; i++ {
+		f := &Grid{wrap: wrap}
+		TheTopology.Link(f)
+		for _, tl := range f.tiles {
+			tl.floor = i
+			tl.walls = 0
+		}
+		g.floors = append(g.floors, f)
+		g.tiles = append(g.tiles, f.tiles...)
+	}
+	return g
+}
+
+// tileAt returns the tile at x,y on a floor of a grid made by openGrid
+func (g *Grid) tileAt(floor, x, y int) *Tile {
+	return g.floors[floor].findTile(x, y)
+}
+
+// pathCost adds up the cost of each step along a path, checking that each step can be taken
+func pathCost(t *testing.T, path []*Tile, cost CostFunc) float64 {
+	total := 0.0
+	for i := 1; i < len(path); i++ {
+		joined := false
+		for _, tn := range path[i-1].openNeighbours() {
+			joined = joined || tn == path[i]
+		}
+		if !joined {
+			t.Fatalf("step %d of the path, from %v to %v, isn't between open neighbours", i, path[i-1], path[i])
+		}
+		total += cost(path[i-1], path[i])
+	}
+	return total
+}
+
+func TestPathfinding(t *testing.T) {
+	inf := math.Inf(1)
+	for _, tc := range []struct {
+		name    string
+		build   func(t *testing.T) (g *Grid, src, dst *Tile, cost CostFunc)
+		want    float64 // the cost of the cheapest path from src to dst, +Inf if there isn't one
+		wantBFS int     // the number of steps on the shortest path, ignoring costs, -1 if there isn't one
+	}{
+		{
+			name: "open",
+			build: func(t *testing.T) (*Grid, *Tile, *Tile, CostFunc) {
+				g := openGrid(t, 5, 5, 1, false)
+				return g, g.tileAt(0, 0, 0), g.tileAt(0, 4, 4), nil
+			},
+			want: 8, wantBFS: 8,
+		},
+		{
+			name: "across the wrap edges",
+			build: func(t *testing.T) (*Grid, *Tile, *Tile, CostFunc) {
+				g := openGrid(t, 5, 5, 1, true)
+				return g, g.tileAt(0, 0, 0), g.tileAt(0, 4, 4), nil
+			},
+			want: 2, wantBFS: 2,
+		},
+		{
+			name: "round an expensive tile",
+			build: func(t *testing.T) (*Grid, *Tile, *Tile, CostFunc) {
+				g := openGrid(t, 3, 3, 1, false)
+				mud := g.tileAt(0, 1, 1)
+				return g, g.tileAt(0, 0, 1), g.tileAt(0, 2, 1), func(from, to *Tile) float64 {
+					if to == mud {
+						return 10
+					}
+					return 1
+				}
+			},
+			want: 4, wantBFS: 2,
+		},
+		{
+			name: "through the only gap in a line of +Inf",
+			build: func(t *testing.T) (*Grid, *Tile, *Tile, CostFunc) {
+				g := openGrid(t, 5, 5, 1, false)
+				gap := g.tileAt(0, 2, 4)
+				return g, g.tileAt(0, 0, 0), g.tileAt(0, 4, 0), func(from, to *Tile) float64 {
+					if to.X == 2 && to != gap {
+						return inf
+					}
+					return 1
+				}
+			},
+			want: 12, wantBFS: 4,
+		},
+		{
+			name: "walled in by +Inf",
+			build: func(t *testing.T) (*Grid, *Tile, *Tile, CostFunc) {
+				g := openGrid(t, 3, 3, 1, false)
+				dst := g.tileAt(0, 2, 2)
+				return g, g.tileAt(0, 0, 0), dst, func(from, to *Tile) float64 {
+					if to == dst {
+						return inf
+					}
+					return 1
+				}
+			},
+			want: inf, wantBFS: 4,
+		},
+		{
+			name: "the way a one way street goes",
+			build: func(t *testing.T) (*Grid, *Tile, *Tile, CostFunc) {
+				g := openGrid(t, 3, 1, 1, false)
+				return g, g.tileAt(0, 0, 0), g.tileAt(0, 2, 0), eastOnly
+			},
+			want: 2, wantBFS: 2,
+		},
+		{
+			name: "against a one way street",
+			build: func(t *testing.T) (*Grid, *Tile, *Tile, CostFunc) {
+				g := openGrid(t, 3, 1, 1, false)
+				return g, g.tileAt(0, 2, 0), g.tileAt(0, 0, 0), eastOnly
+			},
+			want: inf, wantBFS: 2,
+		},
+		{
+			name: "up the stairs",
+			build: func(t *testing.T) (*Grid, *Tile, *Tile, CostFunc) {
+				g := openGrid(t, 3, 3, 2, false)
+				below, above := g.tileAt(0, 2, 0), g.tileAt(1, 2, 0)
+				below.stairs, above.stairs = above, below
+				return g, g.tileAt(0, 0, 0), g.tileAt(1, 2, 2), nil
+			},
+			want: 5, wantBFS: 5,
+		},
+		{
+			name: "no stairs",
+			build: func(t *testing.T) (*Grid, *Tile, *Tile, CostFunc) {
+				g := openGrid(t, 3, 3, 2, false)
+				return g, g.tileAt(0, 0, 0), g.tileAt(1, 2, 2), nil
+			},
+			want: inf, wantBFS: -1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g, src, dst, cost := tc.build(t)
+			costOrOne := cost
+			if costOrOne == nil {
+				costOrOne = func(from, to *Tile) float64 { return 1 }
+			}
+
+			paths := map[string][]*Tile{
+				"Dijkstra": g.DijkstraPath(src, dst, cost),
+				"A*":       g.AStarPath(src, dst, cost),
+			}
+			for name, path := range paths {
+				if math.IsInf(tc.want, 1) {
+					if path != nil {
+						t.Errorf("%s found a path %v that can't be taken", name, path)
+					}
+					continue
+				}
+				if len(path) == 0 || path[0] != src || path[len(path)-1] != dst {
+					t.Fatalf("%s path %v doesn't go from %v to %v", name, path, src, dst)
+				}
+				if c := pathCost(t, path, costOrOne); c != tc.want {
+					t.Errorf("%s path %v costs %v, want %v", name, path, c, tc.want)
+				}
+			}
+
+			d, ok := g.DijkstraDistances(src, cost)[dst]
+			if !ok {
+				d = inf
+			}
+			if d != tc.want {
+				t.Errorf("DijkstraDistances to %v is %v, want %v", dst, d, tc.want)
+			}
+			d, ok = g.DijkstraDistancesTo(dst, cost)[src]
+			if !ok {
+				d = inf
+			}
+			if d != tc.want {
+				t.Errorf("DijkstraDistancesTo from %v is %v, want %v", src, d, tc.want)
+			}
+
+			// BFS ignores costs, so it only cares about the walls and stairs
+			path := g.BFSPath(src, dst)
+			steps, ok := g.BFSDistances(src)[dst]
+			if !ok {
+				steps = -1
+			}
+			if steps != tc.wantBFS || len(path)-1 != tc.wantBFS {
+				t.Errorf("BFS takes %d steps with a path of %d, want %d", steps, len(path)-1, tc.wantBFS)
+			}
+		})
+	}
+}
+
+// eastOnly is a CostFunc that won't step west
+func eastOnly(from, to *Tile) float64 {
+	if to.X < from.X {
+		return math.Inf(1)
+	}
+	return 1
+}
@@ -0,0 +1,228 @@
+package maze
+
+import (
+	"image"
+	"log"
+	"math"
+
+	"github.com/fogleman/gg"
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// polar tile directions; a tile has at most two neighbours in the ring outside it
+const (
+	POLAR_IN   = iota // towards the middle
+	POLAR_CW          // clockwise along the ring
+	POLAR_OUT         // away from the middle, or the first of two tiles if the ring outside is split
+	POLAR_OUT2        // the second tile outside, nil if the ring outside isn't split here
+	POLAR_CCW         // counter-clockwise along the ring
+)
+
+// polarCentreWedges is how many tiles the middle disc is cut into
+const polarCentreWedges = 4
+
+type polarImage struct {
+	key uint
+	img *ebiten.Image
+}
+
+var (
+	// polarRings is the number of tiles in each ring, innermost first, set by Link
+	polarRings []int
+	// polarImages caches each tile's image, because no two rings of tiles are the same shape
+	polarImages = map[*Tile]polarImage{}
+)
+
+// PolarTopology is a circular (theta) maze of TilesDown rings, each TileSize deep.
+// The middle disc is cut into wedges, and rings are split in two whenever their tiles
+// get too wide, so tiles stay roughly square. The two innermost rings form the pen.
+// A tile's X is its position clockwise around its ring, and its Y is the ring number.
+// Only Level.Height (the number of rings) is used: how many tiles go round each ring
+// is worked out from how far out it is, so Level.Width is ignored and can be left out.
+type PolarTopology struct{}
+
+// polarRingSizes returns the number of tiles in each ring
+func polarRingSizes(rings int) []int {
+	sizes := []int{polarCentreWedges}
+	for r := 1; r < rings; r++ {
+		prev := sizes[r-1]
+		// how wide (in tiles) the inside of this ring would be if it wasn't split
+		if width := 2 * math.Pi * float64(r) / float64(prev); width >= 1.5 {
+			sizes = append(sizes, prev*2)
+		} else {
+			sizes = append(sizes, prev)
+		}
+	}
+	return sizes
+}
+
+// polarPoint returns the world coords of the point radius pixels from the middle at angle theta
+func polarPoint(radius, theta float64) (float64, float64) {
+	mid := float64(TilesDown * TileSize)
+	return mid + radius*math.Cos(theta), mid + radius*math.Sin(theta)
+}
+
+// polarBounds returns the inner and outer radius, and the start and end angles, of t
+func polarBounds(t *Tile) (rIn, rOut, theta0, theta1 float64) {
+	n := float64(polarRings[t.Y])
+	rIn = float64(t.Y * TileSize)
+	rOut = rIn + TileSize
+	theta0 = 2 * math.Pi * float64(t.X) / n
+	theta1 = 2 * math.Pi * float64(t.X+1) / n
+	return
+}
+
+// polarSplit returns true if the ring outside t has two tiles against it
+func polarSplit(t *Tile) bool {
+	return t.Y+1 < len(polarRings) && polarRings[t.Y+1] > polarRings[t.Y]
+}
+
+// polarArc adds the points along an arc to pts, from theta0 to theta1 (which may be less than theta0)
+func polarArc(pts [][2]float64, radius, theta0, theta1 float64) [][2]float64 {
+	steps := int(math.Ceil(math.Abs(theta1-theta0) / (math.Pi / 16)))
+	if steps < 2 {
+		steps = 2
+	}
+	for i := 0; i <= steps; i++ {
+		x, y := polarPoint(radius, theta0+(theta1-theta0)*float64(i)/float64(steps))
+		pts = append(pts, [2]float64{x, y})
+	}
+	return pts
+}
+
+// polarOutline returns the outline of t in world coords: along the inside edge
+// clockwise, then back along the outside edge
+func polarOutline(t *Tile) [][2]float64 {
+	rIn, rOut, theta0, theta1 := polarBounds(t)
+	var pts [][2]float64
+	if rIn == 0 {
+		x, y := polarPoint(0, 0)
+		pts = append(pts, [2]float64{x, y})
+	} else {
+		pts = polarArc(pts, rIn, theta0, theta1)
+	}
+	return polarArc(pts, rOut, theta1, theta0)
+}
+
+// Directions implements Topology
+func (PolarTopology) Directions() int {
+	return 5
+}
+
+// Link implements Topology; TilesAcross is set to the number of tiles in the outside ring
+func (PolarTopology) Link(g *Grid) {
+	if g.wrap {
+		log.Fatal("a polar grid can't wrap, as its rings already do")
+	}
+	polarRings = polarRingSizes(TilesDown)
+	polarImages = map[*Tile]polarImage{}
+	TilesAcross = polarRings[len(polarRings)-1]
+
+	g.rows = make([][]*Tile, len(polarRings))
+	for y, n := range polarRings {
+		g.rows[y] = make([]*Tile, n)
+		for x := range g.rows[y] {
+			t := NewTile(x, y)
+			rIn, rOut, theta0, theta1 := polarBounds(t)
+			cx, cy := polarPoint((rIn+rOut)/2, (theta0+theta1)/2)
+			t.worldX = cx - TileSize/2
+			t.worldY = cy - TileSize/2
+			g.rows[y][x] = t
+			g.tiles = append(g.tiles, t)
+		}
+	}
+
+	for _, t := range g.tiles {
+		x := t.X
+		y := t.Y
+		row := g.rows[y]
+		n := len(row)
+		t.edges[POLAR_CW] = row[(x+1)%n]
+		t.edges[POLAR_CCW] = row[(x+n-1)%n]
+		if y > 0 {
+			t.edges[POLAR_IN] = g.rows[y-1][x*len(g.rows[y-1])/n]
+		}
+		if y+1 < len(g.rows) {
+			if polarSplit(t) {
+				t.edges[POLAR_OUT] = g.rows[y+1][x*2]
+				t.edges[POLAR_OUT2] = g.rows[y+1][x*2+1]
+			} else {
+				t.edges[POLAR_OUT] = g.rows[y+1][x]
+			}
+		}
+	}
+}
+
+// Pen implements Topology; the pen is the middle disc and the ring around it
+func (PolarTopology) Pen(g *Grid) []*Tile {
+	var pen []*Tile
+	for y := 0; y < 2 && y < len(g.rows); y++ {
+		pen = append(pen, g.rows[y]...)
+	}
+	return pen
+}
+
+// WallAngle implements Topology
+func (PolarTopology) WallAngle(t *Tile, d Direction) float64 {
+	rIn, rOut, theta0, theta1 := polarBounds(t)
+	thetaMid := (theta0 + theta1) / 2
+	var x, y float64
+	switch d {
+	case POLAR_IN:
+		x, y = polarPoint(rIn, thetaMid)
+	case POLAR_CW:
+		x, y = polarPoint((rIn+rOut)/2, theta1)
+	case POLAR_CCW:
+		x, y = polarPoint((rIn+rOut)/2, theta0)
+	case POLAR_OUT:
+		if polarSplit(t) {
+			x, y = polarPoint(rOut, (theta0+thetaMid)/2)
+		} else {
+			x, y = polarPoint(rOut, thetaMid)
+		}
+	case POLAR_OUT2:
+		if polarSplit(t) {
+			x, y = polarPoint(rOut, (thetaMid+theta1)/2)
+		} else {
+			x, y = polarPoint(rOut, thetaMid)
+		}
+	}
+	cx, cy := t.center()
+	if x == cx && y == cy {
+		return thetaMid
+	}
+	return math.Atan2(y-cy, x-cx)
+}
+
+// Outline implements Topology
+func (PolarTopology) Outline(t *Tile) []image.Point {
+	var pts []image.Point
+	for _, p := range polarOutline(t) {
+		pts = append(pts, image.Point{X: int(math.Round(p[0])), Y: int(math.Round(p[1]))})
+	}
+	return pts
+}
+
+// TileImage implements Topology
+func (PolarTopology) TileImage(t *Tile) *ebiten.Image {
+	key := t.walls
+	if !t.visited {
+		key |= 1 << 5
+	}
+	pi, ok := polarImages[t]
+	if !ok || pi.key != key {
+		pi = polarImage{key: key, img: ebiten.NewImageFromImage(makePolarTileImage(t))}
+		polarImages[t] = pi
+	}
+	return pi.img
+}
+
+// Size implements Topology
+func (PolarTopology) Size() (int, int) {
+	return TilesDown * TileSize * 2, TilesDown * TileSize * 2
+}
+
+// drawMinimapOutline implements minimapOutliner
+func (PolarTopology) drawMinimapOutline(dc *gg.Context, w, h float64) {
+	dc.DrawCircle(w/2, h/2, w/2-1)
+}
@@ -0,0 +1,68 @@
+package maze
+
+import (
+	"log"
+	"math/rand"
+)
+
+// Prim carves a maze using a randomized version of Prim's algorithm,
+// which gives lots of short dead ends radiating from a start tile
+type Prim struct{}
+
+// mark this tile as 'in'
+// and then adds marked/in neighbours to the frontier tiles
+func (t *Tile) primMark(pfrontier *[]*Tile) {
+	t.visited = true
+	for _, dir := range ALL_DIRECTIONS {
+		n := t.edges[dir]
+		if n != nil && !n.visited {
+			*pfrontier = append(*pfrontier, n)
+		}
+	}
+}
+
+// return all the 'in' neighbours
+func (t *Tile) primNeighbours() []*Tile {
+	var lst []*Tile = []*Tile{}
+	for _, dir := range ALL_DIRECTIONS {
+		n := t.edges[dir]
+		if n != nil && n.visited {
+			lst = append(lst, n)
+		}
+	}
+	return lst
+}
+
+// Generate implements Generator
+func (Prim) Generate(g *Grid, rng *rand.Rand) {
+	t := g.tiles[rng.Intn(len(g.tiles))]
+	if t.visited {
+		log.Fatal("Tile.visited is true")
+	}
+	var frontier []*Tile = []*Tile{}
+	t.primMark(&frontier)
+	for len(frontier) > 0 {
+		// remove a random frontier tile
+
+		// we do not care about ordering,
+		// so replace the element to delete with the one at the end of the slice
+		// and then return the first len-1 elements
+		i := rng.Intn(len(frontier))
+		t1 := frontier[i]
+		frontier[i] = frontier[len(frontier)-1]
+		frontier = frontier[:len(frontier)-1]
+		lst := t1.primNeighbours()
+		t2 := lst[rng.Intn(len(lst))]
+		if (t1.visited && !t2.visited) || (!t1.visited && t2.visited) {
+			// remove wall between t1 and t2
+			dir := whichDirIs(t1, t2)
+			if dir == -1 {
+				log.Fatal("dir is -1")
+			}
+			t1.removeWall(dir)
+		}
+		// mark the frontier tile as being 'in' the maze
+		// (and add any of it's outside neighbours to the frontier)
+		t1.primMark(&frontier)
+	}
+}
@@ -10,24 +10,34 @@ import (
 	"oddstream.games/gomaze/util"
 )
 
-// Puck defines the yellow blob/player avatar
+// puckColors are the BasicColors of the pucks in a grid, in the order they're made
+var puckColors = [MaxPucks]string{"Yellow", "Red", "Green", "Blue"}
+
+// Puck defines the yellow (or red, green or blue) blob/player avatar
 type Puck struct {
 	tile                   *Tile   // tile we are sitting on
 	dest                   *Tile   // tile we are lerping to
+	prev                   *Tile   // tile we have just come from, so ice knows which way we were going
 	srcX, srcY, dstX, dstY float64 // positions for lerp
 	lerpstep               float64
 	img                    *ebiten.Image
+	dozerImg               *ebiten.Image // drawn instead of img when bulldozing, blade to the east
 	worldX, worldY         float64
 	col                    color.RGBA
 	ball                   *Ball
+	mark                   uint // this puck's bit in Tile.marked, so each puck follows its own path
+	walls                  int  // number of walls carried, picked up by removing them and spent by placing them
+	fuel                   int  // number of walls the bulldozer can still knock down
+	dozing                 bool
+	dozeAngle              float64 // which way the bulldozer is going, radians clockwise from east
+	dozeFrom               *Tile   // where the bulldozer started, so it stops if it gets all the way round a wrapped grid
 }
 
-// NewPuck creates a new Puck object
-func NewPuck(start *Tile, col color.RGBA) *Puck {
-	p := &Puck{tile: start, col: col}
+// NewPuck creates a new Puck object; each puck in a grid needs a different mark bit
+func NewPuck(start *Tile, col color.RGBA, mark uint) *Puck {
+	p := &Puck{tile: start, col: col, mark: mark}
 	p.createImage()
 	p.worldX, p.worldY = p.tile.position()
-	p.SetCamera()
 	p.ball = NewBall(start, col)
 	return p
 }
@@ -39,6 +49,27 @@ func (p *Puck) createImage() {
 	dc.Fill()
 	dc.Stroke()
 	p.img = ebiten.NewImageFromImage(dc.Image())
+
+	dc = gg.NewContext(TileSize, TileSize)
+	mid := float64(TileSize / 2)
+	third := float64(TileSize / 3)
+	dc.SetColor(p.col)
+	dc.DrawRoundedRectangle(mid-third, mid-third*0.8, third*1.6, third*1.6, float64(TileSize/12))
+	dc.Fill()
+	dc.SetRGBA(0, 0, 0, 0.8)
+	dc.SetLineWidth(float64(TileSize / 10))
+	dc.SetLineCap(gg.LineCapRound)
+	dc.DrawLine(mid+third*0.9, mid-third, mid+third*0.9, mid+third)
+	dc.Stroke()
+	p.dozerImg = ebiten.NewImageFromImage(dc.Image())
+}
+
+// place puts the puck straight on a tile, without lerping there
+func (p *Puck) place(t *Tile) {
+	p.tile = t
+	p.dest = nil
+	p.prev = nil
+	p.worldX, p.worldY = p.tile.position()
 }
 
 // SetCamera so that puck is at the center of the screen
@@ -51,8 +82,24 @@ func (p *Puck) SetCamera() {
 	CameraY = float64(h/2-sy/2) - p.worldY
 }
 
-// ThrowBallTo a target tile
+// ThrowBallTo a target tile, and walk there
 func (p *Puck) ThrowBallTo(targ *Tile) {
+	TheGrid.tally.Throws++
+	p.WalkTo(targ)
+	p.ball.StartThrow(targ)
+}
+
+// DropAt puts the ball straight down on a target tile (the puck has been dragged there), and walks there
+func (p *Puck) DropAt(targ *Tile) {
+	TheGrid.tally.Throws++
+	p.WalkTo(targ)
+	p.ball.Drop(targ)
+}
+
+// WalkTo marks the path to a target tile, for the puck to follow
+func (p *Puck) WalkTo(targ *Tile) {
+
+	p.dozing = false
 
 	// if puck is lerping, stop it
 	if p.dest != nil {
@@ -61,11 +108,110 @@ func (p *Puck) ThrowBallTo(targ *Tile) {
 		p.dest = nil
 	}
 
-	TheGrid.BFS(p.tile, targ)
-	for t := targ; t != nil && t != p.tile; t = t.parent {
-		t.marked = true
+	p.markPath(p.tile, targ)
+}
+
+// route returns the quickest way from one tile to another, going round blocks
+// if it can, and through them (pushing them out of the way) if it can't
+func (p *Puck) route(from, targ *Tile) []*Tile {
+	if path := TheGrid.AStarPath(from, targ, blockCost); path != nil {
+		return path
 	}
-	p.ball.StartThrow(targ)
+	return TheGrid.AStarPath(from, targ, terrainCost)
+}
+
+// markPath marks the quickest way from one tile to another for the puck to follow
+func (p *Puck) markPath(from, targ *Tile) {
+	p.markTiles(p.route(from, targ))
+}
+
+// markTiles marks all but the first tile of path for the puck to follow
+func (p *Puck) markTiles(path []*Tile) {
+	for i := 1; i < len(path); i++ {
+		path[i].marked |= p.mark
+	}
+}
+
+// clearPath unmarks the path this puck was following
+func (p *Puck) clearPath() {
+	TheGrid.AllTiles(func(t *Tile) { t.marked &^= p.mark })
+}
+
+// isMarked returns true if t is on this puck's path
+func (p *Puck) isMarked(t *Tile) bool {
+	return t.marked&p.mark != 0
+}
+
+// target returns the tile the puck is heading for, which is wherever the ball is (or is going)
+func (p *Puck) target() *Tile {
+	if p.ball.dest != nil {
+		return p.ball.dest
+	}
+	return p.ball.tile
+}
+
+// toggleWall picks up the wall of the puck's tile in direction d, or puts a carried one there;
+// it returns false if there was no wall to put, leaving the tile as it was
+func (p *Puck) toggleWall(d Direction) bool {
+	if p.tile.neighbour(d) == nil {
+		return true // the edge of the grid, which always has a wall
+	}
+	c := wallChange{p: p, t: p.tile, d: d, walls: -1}
+	if p.tile.isWall(d) {
+		c.walls = 1
+	} else if p.walls == 0 {
+		return false
+	}
+	c.redo() // doing it the first time is just like doing it again
+	TheGrid.history.record(c)
+	TheGrid.tally.Toggles++
+	return true
+}
+
+// Bulldoze sets the puck driving across the grid at angle (radians clockwise from east),
+// knocking down any walls in its way while it has the fuel, and carrying them off;
+// bulldozing the same way again stops it
+func (p *Puck) Bulldoze(angle float64) {
+	if p.dozing && p.dozeAngle == angle {
+		p.dozing = false
+		return
+	}
+	p.clearPath()
+	p.dozing = true
+	p.dozeAngle = angle
+	p.dozeFrom = p.tile
+}
+
+// shove pushes any block on t out of the puck's way, returning false if there's one that won't budge
+func (p *Puck) shove(t *Tile) bool {
+	if !TheGrid.blockOn(t) {
+		return true
+	}
+	b := TheGrid.blockAt(t)
+	return b != nil && b.push(p.tile)
+}
+
+// doze returns the tile the bulldozer goes to next, knocking down the wall
+// and pushing the block in the way first if it can, or nil if the bulldozer has to stop
+func (p *Puck) doze() *Tile {
+	d := p.tile.wallTowards(p.dozeAngle)
+	tn := p.tile.neighbour(d)
+	if tn == nil || tn == p.dozeFrom {
+		return nil
+	}
+	if p.tile.isWall(d) {
+		if p.fuel == 0 {
+			return nil
+		}
+		c := wallChange{p: p, t: p.tile, d: d, walls: 1, fuel: -1}
+		c.redo()
+		TheGrid.history.record(c)
+		TheGrid.tally.Toggles++
+	}
+	if !p.shove(tn) {
+		return nil
+	}
+	return tn
 }
 
 // String representation of puck
@@ -78,52 +224,98 @@ func (p *Puck) Update() error {
 
 	p.ball.Update()
 
-	if p.tile.marked {
+	// (a path found after being pushed off course may lead back through the tile being left)
+	if p.dest == nil && p.isMarked(p.tile) {
 		// println("unmarking")
-		p.tile.marked = false
+		p.tile.marked &^= p.mark
 	}
 
 	if p.dest == nil {
-		// if any of the neighbours are marked, move there
-		for _, d := range ALL_DIRECTIONS {
-			if p.tile.isWall(d) {
-				continue
+		// the terrain may push the puck somewhere, whatever the marked path says,
+		// but a bulldozer just keeps going
+		next := p.tile.forcedMove(p.prev)
+		p.prev = nil
+		if p.dozing {
+			p.dest = p.doze()
+			p.dozing = p.dest != nil
+		} else if next != nil {
+			p.dest = next
+			if !p.isMarked(next) && p.tile != p.target() {
+				// pushed off the path on the way somewhere, so find another way there
+				p.clearPath()
+				p.markPath(next, p.target())
 			}
-			tn := p.tile.neighbour(d)
-			if tn == nil {
-				log.Fatal("unwalled edge found in Puck Update")
+		} else {
+			// if any of the neighbours are marked, move there
+			for _, d := range ALL_DIRECTIONS {
+				if p.tile.isWall(d) {
+					continue
+				}
+				tn := p.tile.neighbour(d)
+				if tn == nil {
+					log.Fatal("unwalled edge found in Puck Update")
+				}
+				if p.isMarked(tn) {
+					p.dest = tn
+					break
+				}
+			}
+			// the path may go up or down the stairs
+			if p.dest == nil && p.tile.stairs != nil && p.isMarked(p.tile.stairs) {
+				p.dest = p.tile.stairs
 			}
-			if tn.marked {
-				p.dest = tn
-				p.srcX, p.srcY = p.tile.position()
-				p.dstX, p.dstY = p.dest.position()
-				p.lerpstep = 0
-				break
+			if p.dest != nil && !p.shove(p.dest) {
+				// a block won't budge, so go round it if there's a way, otherwise give up
+				p.dest = nil
+				p.clearPath()
+				p.markTiles(TheGrid.AStarPath(p.tile, p.target(), blockCost))
 			}
 		}
+		if p.dest != nil {
+			p.srcX, p.srcY = p.tile.position()
+			p.dstX, p.dstY = p.dest.positionNear(p.srcX, p.srcY)
+			p.lerpstep = 0
+		}
 	} else {
 		if p.lerpstep >= 1 {
+			TheGrid.history.record(puckMove{p: p, from: p.tile, to: p.dest})
+			TheGrid.tally.Moves++
+			p.prev = p.tile
 			p.tile = p.dest
-			p.tile.marked = false
+			p.tile.marked &^= p.mark
 			p.worldX, p.worldY = p.tile.position()
 			p.dest = nil
 		} else {
 			p.worldX = util.Lerp(p.srcX, p.dstX, p.lerpstep)
 			p.worldY = util.Lerp(p.srcY, p.dstY, p.lerpstep)
-			p.lerpstep += 0.05
+			p.lerpstep += 0.05 * p.dest.terrain.speed()
 		}
 	}
-	p.SetCamera()
+	if p == TheGrid.activePuck {
+		p.SetCamera()
+	}
 
 	return nil
 }
 
-// Draw the Puck, and maybe it's ball
+// Draw the Puck, and maybe it's ball; pucks that aren't active are faded
 func (p *Puck) Draw(screen *ebiten.Image) {
 	op := &ebiten.DrawImageOptions{}
+	img := p.img
+	if p.dozing {
+		// turn the blade to face the way the bulldozer is going
+		img = p.dozerImg
+		mid := float64(TileSize / 2)
+		op.GeoM.Translate(-mid, -mid)
+		op.GeoM.Rotate(p.dozeAngle)
+		op.GeoM.Translate(mid, mid)
+	}
 	op.GeoM.Translate(p.worldX, p.worldY)
 	op.GeoM.Translate(CameraX, CameraY)
-	screen.DrawImage(p.img, op)
+	if p != TheGrid.activePuck {
+		op.ColorM.Scale(1, 1, 1, 0.5)
+	}
+	screen.DrawImage(img, op)
 	if p.tile != p.ball.tile {
 		p.ball.Draw(screen)
 	}
@@ -0,0 +1,196 @@
+package maze
+
+import (
+	"image"
+	"math"
+
+	"github.com/fogleman/gg"
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const (
+	// radialInner and radialOuter are the radii of the ring of a RadialMenu, in pixels
+	radialInner = TileSize * 0.55
+	radialOuter = TileSize * 1.25
+)
+
+// RadialMenu is a ring of segments around a stationary puck, one for each wall of its tile,
+// showing whether the wall is there, and toggling it when tapped; Widget and Observer.
+// Pressing on a segment (or hovering over it with the mouse) previews what tapping it would do.
+type RadialMenu struct {
+	p        *Puck
+	toggle   func(Direction) // picks up or puts down a wall of the puck's tile
+	dismiss  func()          // closes the menu
+	origin   image.Point     // the middle of the ring, in screen coords
+	pressed  bool            // the pointer is down, having gone down on the menu
+	selected Direction       // the segment being previewed, or -1
+	img      *ebiten.Image
+	drawn    radialMenuLook // what img shows, so it's only drawn again when something changes
+}
+
+// radialMenuLook is everything that changes how a RadialMenu looks
+type radialMenuLook struct {
+	walls    uint
+	canPlace bool
+	selected Direction
+}
+
+// NewRadialMenu creates a RadialMenu for the walls of the tile puck p is on
+func NewRadialMenu(p *Puck, toggleFn func(Direction), dismissFn func()) *RadialMenu {
+	return &RadialMenu{p: p, toggle: toggleFn, dismiss: dismissFn, selected: -1}
+}
+
+// segmentAt returns the direction of the wall whose segment pt (in screen coords) is on,
+// or -1 if it isn't on the ring, or on the edge of the grid, where the walls can't be moved
+func (rm *RadialMenu) segmentAt(pt image.Point) Direction {
+	dx, dy := float64(pt.X-rm.origin.X), float64(pt.Y-rm.origin.Y)
+	if r := math.Hypot(dx, dy); r < radialInner || r > radialOuter {
+		return -1
+	}
+	d := rm.p.tile.wallTowards(math.Atan2(dy, dx))
+	if rm.p.tile.neighbour(d) == nil {
+		return -1
+	}
+	return d
+}
+
+// NotifyCallback is passed pointer events by Grid while the menu is open
+func (rm *RadialMenu) NotifyCallback(event interface{}) {
+	switch v := event.(type) {
+	case PointerEvent:
+		switch v.Kind {
+		case PointerPress:
+			rm.selected = rm.segmentAt(v.Pt)
+			rm.pressed = rm.selected >= 0
+		case PointerDrag:
+			if rm.pressed {
+				rm.selected = rm.segmentAt(v.Pt)
+			}
+		case PointerRelease:
+			// only toggle the wall if the pointer comes up on the segment it went down on
+			if rm.pressed && rm.segmentAt(v.Pt) == rm.selected {
+				rm.Action()
+			} else if v.Dragged && !rm.contains(v.Pt) {
+				rm.dismiss()
+			}
+			rm.pressed = false
+			rm.selected = -1
+		}
+	case image.Point:
+		// a tap off the menu, or on the puck in the middle, closes it
+		if !rm.contains(v) || rm.segmentAt(v) < 0 {
+			rm.dismiss()
+		}
+	}
+}
+
+// contains returns true if pt (in screen coords) is on the ring or inside it
+func (rm *RadialMenu) contains(pt image.Point) bool {
+	return math.Hypot(float64(pt.X-rm.origin.X), float64(pt.Y-rm.origin.Y)) <= radialOuter
+}
+
+// SetPosition sets the position of the middle of the menu in screen coords
+func (rm *RadialMenu) SetPosition(x, y int) {
+	rm.origin = image.Point{X: x, Y: y}
+}
+
+// Rect gives the x,y coords of the menu's top left and bottom right corners, in screen coordinates
+func (rm *RadialMenu) Rect() (x0 int, y0 int, x1 int, y1 int) {
+	x0 = rm.origin.X - int(radialOuter)
+	y0 = rm.origin.Y - int(radialOuter)
+	x1 = rm.origin.X + int(radialOuter)
+	y1 = rm.origin.Y + int(radialOuter)
+	return // using named return parameters
+}
+
+// Action toggles the wall of the selected segment
+func (rm *RadialMenu) Action() {
+	if rm.selected >= 0 {
+		rm.toggle(rm.selected)
+	}
+}
+
+// Update the menu state; the mouse previews the segment it's over without being pressed
+func (rm *RadialMenu) Update() error {
+	if !rm.pressed {
+		x, y := ebiten.CursorPosition()
+		rm.selected = rm.segmentAt(image.Point{X: x, Y: y})
+	}
+	return nil
+}
+
+// createImage draws the ring; each segment has a bar like a wall across it, solid if
+// the wall is there and dashed if it isn't. The selected segment shows what it will be
+// once toggled, green if a wall can go there and red if it can't.
+func (rm *RadialMenu) createImage(look radialMenuLook) *ebiten.Image {
+	size := int(radialOuter * 2)
+	mid := radialOuter
+	dc := gg.NewContext(size, size)
+	t := rm.p.tile
+
+	// how wide each segment is; on polar tiles, the walls aren't evenly spaced
+	halfWidth := func(d Direction) float64 {
+		half := math.Pi
+		for _, d2 := range ALL_DIRECTIONS {
+			if d2 != d && t.neighbour(d2) != nil {
+				half = math.Min(half, math.Abs(angleBetween(TheTopology.WallAngle(t, d), TheTopology.WallAngle(t, d2)))/2)
+			}
+		}
+		return half
+	}
+
+	for _, d := range ALL_DIRECTIONS {
+		if t.neighbour(d) == nil {
+			continue
+		}
+		a, half := TheTopology.WallAngle(t, d), halfWidth(d)-0.04
+		dc.NewSubPath()
+		dc.DrawArc(mid, mid, radialOuter-2, a-half, a+half)
+		dc.DrawArc(mid, mid, radialInner, a+half, a-half)
+		dc.ClosePath()
+		if d == look.selected {
+			dc.SetRGBA(0, 0, 0, 0.8)
+		} else {
+			dc.SetRGBA(0, 0, 0, 0.5)
+		}
+		dc.Fill()
+
+		wall := look.walls&(1<<d) != 0
+		dc.SetRGBA(1, 1, 1, 0.9)
+		if d == look.selected {
+			wall = !wall
+			if !wall || look.canPlace {
+				dc.SetRGB(0, 1, 0)
+			} else {
+				dc.SetRGB(1, 0, 0)
+			}
+		}
+		// the bar goes across the middle of the segment, square on to it
+		r := (radialInner + radialOuter) / 2
+		cx, cy := mid+r*math.Cos(a), mid+r*math.Sin(a)
+		bar := r * math.Sin(half) * 0.8
+		px, py := -math.Sin(a)*bar, math.Cos(a)*bar
+		dc.SetLineWidth(float64(TileSize / 12))
+		dc.SetLineCap(gg.LineCapRound)
+		if wall {
+			dc.SetDash()
+		} else {
+			dc.SetDash(float64(TileSize/24), float64(TileSize/12))
+		}
+		dc.DrawLine(cx-px, cy-py, cx+px, cy+py)
+		dc.Stroke()
+	}
+	return ebiten.NewImageFromImage(dc.Image())
+}
+
+// Draw handles rendering of RadialMenu object
+func (rm *RadialMenu) Draw(screen *ebiten.Image) {
+	look := radialMenuLook{walls: rm.p.tile.walls, canPlace: rm.p.walls > 0, selected: rm.selected}
+	if rm.img == nil || look != rm.drawn {
+		rm.img = rm.createImage(look)
+		rm.drawn = look
+	}
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Translate(float64(rm.origin.X)-radialOuter, float64(rm.origin.Y)-radialOuter)
+	screen.DrawImage(rm.img, op)
+}
@@ -0,0 +1,148 @@
+package maze
+
+import "log"
+
+// Reachability is kept up to date as the puck adds and removes walls, without walking
+// the whole grid every time. Every tile belongs to a component, a group of tiles that
+// are all joined together, and a tile is reachable (Tile.visited) if it's in the same
+// component as the pen. Opening a wall may join two components into one, closing
+// a wall may split one in two; either way only the tiles in the components on
+// each side of the wall are looked at.
+
+// labelComponents numbers the components of the whole grid from scratch, after visitTiles
+func (g *Grid) labelComponents() {
+	g.componentSizes = nil
+	g.AllTiles(func(t *Tile) { t.component = -1 })
+	g.AllTiles(func(t *Tile) {
+		if t.component == -1 {
+			g.relabel(floodComponent(t), g.newComponent(), t.visited)
+		}
+	})
+}
+
+// newComponent returns an unused component number, reusing the number of a component
+// that has been emptied if there is one, so componentSizes doesn't grow forever
+func (g *Grid) newComponent() int {
+	for id, n := range g.componentSizes {
+		if n == 0 {
+			return id
+		}
+	}
+	g.componentSizes = append(g.componentSizes, 0)
+	return len(g.componentSizes) - 1
+}
+
+// relabel moves tiles into component id, and makes them reachable or not
+func (g *Grid) relabel(tiles map[*Tile]bool, id int, reachable bool) {
+	for t := range tiles {
+		if t.component >= 0 {
+			g.componentSizes[t.component]--
+		}
+		t.component = id
+		t.visited = reachable
+	}
+	g.componentSizes[id] += len(tiles)
+}
+
+// floodComponent returns the tiles joined to t that are in the same component as t
+// (which, just after a wall has been opened, may not be all the tiles joined to t)
+func floodComponent(t *Tile) map[*Tile]bool {
+	seen := map[*Tile]bool{t: true}
+	q := []*Tile{t}
+	for len(q) > 0 {
+		t := q[0]
+		q = q[1:]
+		for _, tn := range t.openNeighbours() {
+			if !seen[tn] && tn.component == t.component {
+				seen[tn] = true
+				q = append(q, tn)
+			}
+		}
+	}
+	return seen
+}
+
+// joined is called when the wall between a and b has just been opened
+func (g *Grid) joined(a, b *Tile) {
+	if a.component == b.component {
+		return // there was already another way round
+	}
+	// move the tiles of one side into the other's component; if one side can reach
+	// the pen it has to be the other side that moves, as all its tiles become reachable,
+	// otherwise the smaller side moves, as that's less work
+	from, to := a, b
+	if from.visited || (!to.visited && g.componentSizes[from.component] > g.componentSizes[to.component]) {
+		from, to = to, from
+	}
+	g.relabel(floodComponent(from), to.component, to.visited)
+}
+
+// parted is called when the wall between a and b (which were in the same component) has just been closed
+func (g *Grid) parted(a, b *Tile) {
+	// search out from both sides at once; if the searches meet, a and b are still joined,
+	// otherwise whichever search runs out first has found all of its side, without
+	// having to search the (maybe much bigger) other side
+	seenA, seenB := map[*Tile]bool{a: true}, map[*Tile]bool{b: true}
+	qa, qb := []*Tile{a}, []*Tile{b}
+	step := func(q *[]*Tile, seen, other map[*Tile]bool) bool {
+		t := (*q)[0]
+		*q = (*q)[1:]
+		for _, tn := range t.openNeighbours() {
+			if other[tn] {
+				return true
+			}
+			if !seen[tn] {
+				seen[tn] = true
+				*q = append(*q, tn)
+			}
+		}
+		return false
+	}
+	var side map[*Tile]bool
+	for side == nil {
+		if step(&qa, seenA, seenB) || step(&qb, seenB, seenA) {
+			return
+		}
+		if len(qa) == 0 {
+			side = seenA
+		} else if len(qb) == 0 {
+			side = seenB
+		}
+	}
+	// the side that has been cut off is the one without the pen
+	if pen := TheTopology.Pen(g)[0]; side[pen] {
+		other := b
+		if side[b] {
+			other = a
+		}
+		g.relabel(floodComponent(other), g.newComponent(), false)
+		return
+	}
+	g.relabel(side, g.newComponent(), false)
+}
+
+// checkReachability is a debug self-check that the incremental reachability
+// agrees with walking the whole grid again
+func (g *Grid) checkReachability() {
+	var tiles []*Tile
+	var want []bool
+	g.AllTiles(func(t *Tile) {
+		tiles = append(tiles, t)
+		want = append(want, t.visited)
+	})
+	g.visitTiles()
+	for i, t := range tiles {
+		if t.visited != want[i] {
+			log.Fatal("incremental reachability doesn't match a full rescan at ", t, " on floor ", t.floor)
+		}
+	}
+	sizes := make([]int, len(g.componentSizes))
+	for _, t := range tiles {
+		sizes[t.component]++
+	}
+	for id, n := range sizes {
+		if n != g.componentSizes[id] {
+			log.Fatal("component ", id, " has ", n, " tiles, not ", g.componentSizes[id])
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package maze
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestReachability toggles random walls on every level, checking after each toggle
+// that the incremental reachability agrees with walking the whole grid again
+func TestReachability(t *testing.T) {
+	const toggles = 2000
+	TheUserData = &UserData{}
+	for level := range LevelData {
+		TheGrid = NewGrid(level, 1)
+		g := TheGrid
+		var tiles []*Tile
+		g.AllTiles(func(tt *Tile) { tiles = append(tiles, tt) })
+		rng := rand.New(rand.NewSource(int64(level)))
+		for i := 0; i < toggles; i++ {
+			tl := tiles[rng.Intn(len(tiles))]
+			d := ALL_DIRECTIONS[rng.Intn(len(ALL_DIRECTIONS))]
+			if tl.neighbour(d) == nil {
+				continue
+			}
+			g.toggleWall(tl, d)
+
+			want := make(map[*Tile]bool, len(tiles))
+			for _, tt := range tiles {
+				want[tt] = tt.visited
+			}
+			g.visitTiles()
+			for _, tt := range tiles {
+				if tt.visited != want[tt] {
+					t.Fatalf("level %d toggle %d: %v reachable is %v, a full rescan says %v", level, i, tt, want[tt], tt.visited)
+				}
+			}
+
+			sizes := make([]int, len(g.componentSizes))
+			for _, tt := range tiles {
+				sizes[tt.component]++
+				for _, tn := range tt.openNeighbours() {
+					if tn.component != tt.component {
+						t.Fatalf("level %d toggle %d: %v and %v are joined but in components %d and %d", level, i, tt, tn, tt.component, tn.component)
+					}
+				}
+			}
+			for id, n := range sizes {
+				if n != g.componentSizes[id] {
+					t.Fatalf("level %d toggle %d: component %d has %d tiles, not %d", level, i, id, n, g.componentSizes[id])
+				}
+			}
+			if len(g.componentSizes) > len(tiles) {
+				t.Fatalf("level %d toggle %d: %d component numbers for %d tiles", level, i, len(g.componentSizes), len(tiles))
+			}
+		}
+	}
+}
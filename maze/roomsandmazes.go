@@ -0,0 +1,196 @@
+package maze
+
+import (
+	"image"
+	"math/rand"
+)
+
+// RoomsAndMazes places non-overlapping rectangular rooms, fills the gaps between
+// them with a maze, then knocks doors through to join everything together.
+// See http://journal.stuffwithstuff.com/2014/12/21/rooms-and-mazes/
+//
+// Open rooms let the kittens scatter, which makes herding them a lot harder.
+type RoomsAndMazes struct {
+	Attempts       int  // number of times to try placing a room
+	MinSize        int  // smallest room width/height in tiles, default 2
+	MaxSize        int  // largest room width/height in tiles, default 4
+	Doors          int  // number of doors each room should have, at least 1
+	RemoveDeadEnds bool // fill in corridors that go nowhere, leaving solid walled-in tiles
+}
+
+// placeRooms tries to put Attempts rooms on the grid, keeping a corridor's width
+// between each room and the pen. Rooms are rectangles of tile X,Y coords,
+// which on hex tiles gives a lopsided blob, and on polar tiles a chunk of ring.
+func (rm RoomsAndMazes) placeRooms(g *Grid, rng *rand.Rand) []image.Rectangle {
+	minSize, maxSize := rm.MinSize, rm.MaxSize
+	if minSize < 2 {
+		minSize = 2
+	}
+	if maxSize < minSize {
+		maxSize = minSize + 2
+	}
+
+	// tiles that a new room may not use, because they are in or next to the pen or another room
+	taken := map[*Tile]bool{}
+	take := func(t *Tile) {
+		taken[t] = true
+		for _, tn := range t.edges {
+			if tn != nil {
+				taken[tn] = true
+			}
+		}
+	}
+	for _, t := range g.tiles {
+		if t.pen {
+			take(t)
+		}
+	}
+
+	var rooms []image.Rectangle
+	for i := 0; i < rm.Attempts; i++ {
+		w := minSize + rng.Intn(maxSize-minSize+1)
+		h := minSize + rng.Intn(maxSize-minSize+1)
+		if w > TilesAcross || h > TilesDown {
+			continue
+		}
+		x := rng.Intn(TilesAcross - w + 1)
+		y := rng.Intn(TilesDown - h + 1)
+		r := image.Rect(x, y, x+w, y+h)
+		ok := true
+		rm.eachTile(g, r, func(t *Tile) {
+			// rows may be ragged, so a room can fall off the end of one
+			if t == nil || taken[t] {
+				ok = false
+			}
+		})
+		if ok {
+			rm.eachTile(g, r, take)
+			rooms = append(rooms, r)
+		}
+	}
+	return rooms
+}
+
+// Generate implements Generator
+func (rm RoomsAndMazes) Generate(g *Grid, rng *rand.Rand) {
+	rooms := rm.placeRooms(g, rng)
+
+	roomOf := map[*Tile]int{} // room number + 1, so zero means not in a room
+	for i, r := range rooms {
+		rm.eachTile(g, r, func(t *Tile) {
+			roomOf[t] = i + 1
+			t.visited = true
+		})
+		// open up the inside of the room, leaving the walls around it
+		rm.eachTile(g, r, func(t *Tile) {
+			for _, d := range ALL_DIRECTIONS {
+				if tn := t.neighbour(d); tn != nil && roomOf[tn] == i+1 {
+					t.removeWall(d)
+				}
+			}
+		})
+	}
+
+	// the pen has already been opened up, so leave it alone
+	for _, t := range g.tiles {
+		if t.pen {
+			t.visited = true
+		}
+	}
+
+	// fill the spaces between the rooms with mazes; rooms may split the space into
+	// more than one area, so keep starting new mazes until every tile is used
+	for _, t := range g.tiles {
+		if !t.visited {
+			t.visited = true
+			t.recursiveBacktracker(rng)
+		}
+	}
+
+	// find out what's connected to what so far
+	ds := disjointSet{}
+	type wall struct {
+		t *Tile
+		d Direction
+	}
+	var walls []wall
+	for _, t := range g.tiles {
+		for _, d := range ALL_DIRECTIONS {
+			tn := t.neighbour(d)
+			if tn == nil {
+				continue
+			}
+			if t.isWall(d) {
+				walls = append(walls, wall{t: t, d: d})
+			} else {
+				ds.union(t, tn)
+			}
+		}
+	}
+
+	// knock through just enough walls to join all the rooms and mazes together
+	rng.Shuffle(len(walls), func(i, j int) { walls[i], walls[j] = walls[j], walls[i] })
+	for _, w := range walls {
+		if ds.union(w.t, w.t.neighbour(w.d)) {
+			w.t.removeWall(w.d)
+		}
+	}
+
+	// then give each room any extra doors it wants
+	for i, r := range rooms {
+		var doors, closed []wall
+		rm.eachTile(g, r, func(t *Tile) {
+			for _, d := range ALL_DIRECTIONS {
+				tn := t.neighbour(d)
+				if tn == nil || roomOf[tn] == i+1 {
+					continue
+				}
+				if t.isWall(d) {
+					closed = append(closed, wall{t: t, d: d})
+				} else {
+					doors = append(doors, wall{t: t, d: d})
+				}
+			}
+		})
+		rng.Shuffle(len(closed), func(i, j int) { closed[i], closed[j] = closed[j], closed[i] })
+		for n := len(doors); n < rm.Doors && len(closed) > 0; n++ {
+			closed[0].t.removeWall(closed[0].d)
+			closed = closed[1:]
+		}
+	}
+
+	if rm.RemoveDeadEnds {
+		rm.removeDeadEnds(g)
+	}
+}
+
+// eachTile calls fn for each tile in a room, in row order
+func (rm RoomsAndMazes) eachTile(g *Grid, r image.Rectangle, fn func(*Tile)) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			fn(g.findTile(x, y))
+		}
+	}
+}
+
+// removeDeadEnds repeatedly walls up tiles with only one way out, until none are left.
+// Corridors leading straight off the pen are left open, so the pen keeps its entrances.
+func (rm RoomsAndMazes) removeDeadEnds(g *Grid) {
+	for done := false; !done; {
+		done = true
+		for _, t := range g.tiles {
+			if t.pen || !t.isCulDeSac() {
+				continue
+			}
+			for _, d := range ALL_DIRECTIONS {
+				if !t.isWall(d) {
+					if !t.neighbour(d).pen {
+						t.addWall(d)
+						done = false
+					}
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package maze
+
+import "github.com/hajimehoshi/ebiten/v2"
+
+// Tally counts what it took to herd all the ghosts into the pen.
+// Everything counts, even if it was undone afterwards.
+type Tally struct {
+	Ticks   int // update ticks since the level started
+	Moves   int // tiles travelled by all the pucks
+	Throws  int // balls thrown, or dropped by dragging a puck
+	Toggles int // walls picked up, put down or bulldozed
+}
+
+// Scoring says how a level is scored: Base points, less so many for each second taken,
+// and for each move, throw and toggle; a score is never less than zero
+type Scoring struct {
+	Base      int
+	PerSecond int
+	PerMove   int
+	PerThrow  int
+	PerToggle int
+}
+
+// defaultScoring is used by levels that don't say how they are scored
+var defaultScoring = Scoring{Base: 10000, PerSecond: 10, PerMove: 2, PerThrow: 20, PerToggle: 50}
+
+// Score works out the score for a tally
+func (s Scoring) Score(t Tally) int {
+	if s == (Scoring{}) {
+		s = defaultScoring
+	}
+	score := s.Base -
+		s.PerSecond*t.Ticks/ebiten.DefaultTPS -
+		s.PerMove*t.Moves -
+		s.PerThrow*t.Throws -
+		s.PerToggle*t.Toggles
+	return max(score, 0)
+}
+
+// recordScore remembers score for a level if it's the best so far, returning true if it is
+func (ud *UserData) recordScore(level, score int) bool {
+	for len(ud.BestScores) <= level {
+		ud.BestScores = append(ud.BestScores, 0)
+	}
+	if score <= ud.BestScores[level] {
+		return false
+	}
+	ud.BestScores[level] = score
+	return true
+}
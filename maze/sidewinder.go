@@ -0,0 +1,33 @@
+package maze
+
+import (
+	"math/rand"
+)
+
+// Sidewinder carves a maze one row at a time, making runs of eastward
+// passages and then linking each run to the row above.
+// The top row is always one long corridor. Square tiles only.
+type Sidewinder struct{}
+
+// Generate implements Generator
+func (Sidewinder) Generate(g *Grid, rng *rand.Rand) {
+	needSquareTiles(g, "Sidewinder")
+
+	for y := 0; y < TilesDown; y++ {
+		var run []*Tile
+		for x := 0; x < TilesAcross; x++ {
+			t := g.findTile(x, y)
+			run = append(run, t)
+			atEastEdge := t.neighbour(EAST) == nil
+			atNorthEdge := t.neighbour(NORTH) == nil
+			if atEastEdge || (!atNorthEdge && rng.Intn(2) == 0) {
+				if !atNorthEdge {
+					run[rng.Intn(len(run))].removeWall(NORTH)
+				}
+				run = run[:0]
+			} else {
+				t.removeWall(EAST)
+			}
+		}
+	}
+}
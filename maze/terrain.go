@@ -0,0 +1,235 @@
+package maze
+
+import (
+	"image"
+	"math"
+
+	"github.com/fogleman/gg"
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// Terrain is what the floor of a tile is made of, which changes how things move across it
+type Terrain int
+
+const (
+	PLAIN    Terrain = iota // nothing special
+	MUD                     // slow to cross, and so costly to path through
+	ICE                     // anything stepping onto ice keeps sliding the same way until it hits a wall
+	CONVEYOR                // pushes anything on it towards Tile.conveyor
+)
+
+// TerrainMix is the fraction (0.0 .. 1.0) of tiles of a level that get each sort of terrain
+type TerrainMix struct {
+	Mud, Ice, Conveyor float64
+}
+
+var (
+	// terrainImages are drawn under a tile's walls, indexed by Terrain; PLAIN has no image
+	terrainImages [4]*ebiten.Image
+	// conveyorArrowImage points east, and is rotated to point the way a conveyor goes
+	conveyorArrowImage *ebiten.Image
+)
+
+func init() {
+	terrainImages[MUD] = ebiten.NewImageFromImage(makeTerrainImage(0.45, 0.3, 0.15))
+	terrainImages[ICE] = ebiten.NewImageFromImage(makeTerrainImage(0.75, 0.9, 1))
+	terrainImages[CONVEYOR] = ebiten.NewImageFromImage(makeTerrainImage(0.5, 0.5, 0.5))
+	conveyorArrowImage = ebiten.NewImageFromImage(makeConveyorArrowImage())
+}
+
+// makeTerrainImage draws a pool of colour, small enough to sit inside a tile of any topology
+func makeTerrainImage(r, g, b float64) image.Image {
+	dc := gg.NewContext(TileSize, TileSize)
+	mid := float64(TileSize / 2)
+	dc.SetRGBA(r, g, b, 0.6)
+	dc.DrawCircle(mid, mid, float64(TileSize)*0.35)
+	dc.Fill()
+	return dc.Image()
+}
+
+// makeConveyorArrowImage draws an arrow pointing east
+func makeConveyorArrowImage() image.Image {
+	dc := gg.NewContext(TileSize, TileSize)
+	mid := float64(TileSize / 2)
+	arm := float64(TileSize / 6)
+	dc.SetRGBA(1, 1, 1, 0.8)
+	dc.SetLineWidth(float64(TileSize / 16))
+	dc.SetLineCap(gg.LineCapRound)
+	dc.SetLineJoin(gg.LineJoinRound)
+	for _, dx := range []float64{-arm / 2, arm / 2} {
+		dc.MoveTo(mid-arm/2+dx, mid-arm)
+		dc.LineTo(mid+arm/2+dx, mid)
+		dc.LineTo(mid-arm/2+dx, mid+arm)
+		dc.NewSubPath()
+	}
+	dc.Stroke()
+	return dc.Image()
+}
+
+// cost is how many steps' worth of effort it takes to move onto this terrain
+func (tr Terrain) cost() float64 {
+	if tr == MUD {
+		return 3
+	}
+	return 1
+}
+
+// speed scales how fast things lerp onto this terrain
+func (tr Terrain) speed() float64 {
+	switch tr {
+	case MUD:
+		return 1.0 / 3
+	case ICE:
+		return 1.5
+	}
+	return 1
+}
+
+// terrainCost is a CostFunc that allows for terrain: mud is slow, the only way
+// off a conveyor is the way it's going, unless there's a wall in the way, and routes
+// never go onto ice, as anything stepping onto ice slides on past wherever the route
+// would have it stop (the puck and meanies can still end up on ice by being pushed there)
+func terrainCost(from, to *Tile) float64 {
+	if to.terrain == ICE {
+		return math.Inf(1)
+	}
+	if from.terrain == CONVEYOR {
+		if next := from.forcedMove(nil); next != nil && next != to {
+			return math.Inf(1)
+		}
+	}
+	return to.terrain.cost()
+}
+
+// forcedMove returns the tile that the terrain of t pushes anything that has just
+// arrived from the tile from onto, or nil if it can go where it likes.
+// Ice carries on the way it was going, which only makes sense when the move
+// wasn't up or down stairs; a wall or a block stops both ice and conveyors.
+func (t *Tile) forcedMove(from *Tile) *Tile {
+	var d Direction
+	switch t.terrain {
+	case ICE:
+		if from == nil {
+			return nil
+		}
+		d = whichDirIs(from, t)
+		if d < 0 {
+			return nil
+		}
+		// the way out of t on the far side from the way in
+		d = t.wallTowards(TheTopology.WallAngle(from, d))
+	case CONVEYOR:
+		d = t.conveyor
+	default:
+		return nil
+	}
+	if t.isWall(d) {
+		return nil
+	}
+	tn := t.neighbour(d)
+	if TheGrid.blockOn(tn) {
+		return nil
+	}
+	return tn
+}
+
+// placeTerrain scatters terrain over tiles, away from the pen and stairs.
+// Terrain belongs to the tiles, not the walls, so it stays put when the maze is rebuilt.
+func (g *Grid) placeTerrain(mix TerrainMix) {
+	if mix == (TerrainMix{}) {
+		return
+	}
+	for _, t := range g.tiles {
+		if t.pen || t.stairs != nil {
+			continue
+		}
+		switch r := g.rng.Float64(); {
+		case r < mix.Mud:
+			t.terrain = MUD
+		case r < mix.Mud+mix.Ice:
+			if !conveyedOnto(t) && !slidesTo(t, t) {
+				t.terrain = ICE
+			}
+		case r < mix.Mud+mix.Ice+mix.Conveyor:
+			var dirs []Direction
+			for _, d := range ALL_DIRECTIONS {
+				if tn := t.neighbour(d); tn != nil && tn.terrain != ICE && !conveysTo(tn, t) {
+					dirs = append(dirs, d)
+				}
+			}
+			if len(dirs) > 0 {
+				t.terrain = CONVEYOR
+				t.conveyor = dirs[g.rng.Intn(len(dirs))]
+			}
+		}
+	}
+}
+
+// conveysTo returns true if the conveyors starting at t carry anything on them round to dst.
+// Conveyors that lead back to where they started would push things round and round
+// forever (as a conveyor always wins over a marked path), so placeTerrain never makes them;
+// nor does it have conveyors push onto ice, which could slide things back onto a conveyor.
+// Walls don't count, as the maze is carved after the terrain is placed, and walls move.
+func conveysTo(t, dst *Tile) bool {
+	for t != nil && t.terrain == CONVEYOR {
+		if t = t.neighbour(t.conveyor); t == dst {
+			return true
+		}
+	}
+	return false
+}
+
+// slidesTo returns true if something sliding off t in any direction, over the ice already placed,
+// could slide onto dst. Ice that slides back onto itself (across the edges of a level that wraps,
+// or round a ring of a polar level) would carry things round forever if the walls don't stop them,
+// so placeTerrain never makes it. Like conveysTo, it doesn't count the walls.
+func slidesTo(t, dst *Tile) bool {
+	for _, dir := range ALL_DIRECTIONS {
+		from, d := t, dir
+		for tn := from.neighbour(d); tn != nil; tn = from.neighbour(d) {
+			if tn == dst {
+				return true
+			}
+			if tn.terrain != ICE {
+				break
+			}
+			// the way out of tn on the far side from the way in, as forcedMove does it
+			d = tn.wallTowards(TheTopology.WallAngle(from, d))
+			from = tn
+		}
+	}
+	return false
+}
+
+// conveyedOnto returns true if a neighbouring conveyor pushes things onto t
+func conveyedOnto(t *Tile) bool {
+	for _, d := range ALL_DIRECTIONS {
+		if tn := t.neighbour(d); tn != nil && tn.terrain == CONVEYOR && tn.neighbour(tn.conveyor) == t {
+			return true
+		}
+	}
+	return false
+}
+
+// DrawTerrain renders the floor of a tile, which goes under its walls
+func (t *Tile) DrawTerrain(screen *ebiten.Image) {
+	if t.terrain == PLAIN {
+		return
+	}
+	img := terrainImages[t.terrain]
+	op := &ebiten.DrawImageOptions{}
+	t.centerImage(op, img)
+	op.GeoM.Translate(CameraX, CameraY)
+	screen.DrawImage(img, op)
+
+	if t.terrain == CONVEYOR {
+		op := &ebiten.DrawImageOptions{}
+		mid := float64(TileSize / 2)
+		op.GeoM.Translate(-mid, -mid)
+		op.GeoM.Rotate(TheTopology.WallAngle(t, t.conveyor))
+		op.GeoM.Translate(mid, mid)
+		t.centerImage(op, conveyorArrowImage)
+		op.GeoM.Translate(CameraX, CameraY)
+		screen.DrawImage(conveyorArrowImage, op)
+	}
+}
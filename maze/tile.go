@@ -4,14 +4,15 @@ import (
 	"fmt"
 	"image"
 	"log"
-	"math/rand"
+	"math"
+	"math/bits"
 
 	"github.com/fogleman/gg"
 	"github.com/hajimehoshi/ebiten/v2"
 	"oddstream.games/gomaze/util"
 )
 
-// NORTH_WALL EAST_WALL SOUTH_WALL WEST_WALL bit patterns for presence of walls
+// NORTH_WALL EAST_WALL SOUTH_WALL WEST_WALL bit patterns for presence of walls on square tiles
 const (
 	NORTH_WALL = 0b0001 // 1 << iota
 	EAST_WALL  = 0b0010 // 1 << 1
@@ -29,11 +30,12 @@ var (
 	reachableImages   map[uint]*ebiten.Image
 	unreachableImages map[uint]*ebiten.Image
 	dotImage          *ebiten.Image
-	overSize          float64
-	wallbits          = [4]uint{NORTH_WALL, EAST_WALL, SOUTH_WALL, WEST_WALL} // map a direction (0..3) to it's bits
-	wallopps          = [4]uint{SOUTH_WALL, WEST_WALL, NORTH_WALL, EAST_WALL} // map a direction (0..3) to it's opposite bits
-	// oppdirs  = [4]int{2, 3, 0, 1}
-	ALL_DIRECTIONS = [4]Direction{NORTH, EAST, SOUTH, WEST}
+	stairsImages      [2]*ebiten.Image // down, up
+	// crossingImages caches images of crossing tiles, keyed by walls, plus 1<<4 if the tunnel
+	// runs east-west, plus 1<<5 if unreachable
+	crossingImages = map[uint]*ebiten.Image{}
+	// ALL_DIRECTIONS a tile can have a wall in, set by setTopology
+	ALL_DIRECTIONS = []Direction{NORTH, EAST, SOUTH, WEST}
 )
 
 type Direction int
@@ -54,11 +56,8 @@ func init() {
 		unreachableImages[i] = ebiten.NewImageFromImage(img)
 	}
 
-	// the tiles are all the same size, so pre-calc some useful variables
-	actualTileSize := reachableImages[0].Bounds().Dx()
-	overSize = float64((actualTileSize - TileSize) / 2)
-
 	{
+		actualTileSize := reachableImages[0].Bounds().Dx()
 		mid := float64(actualTileSize / 2)
 		dc := gg.NewContext(actualTileSize, actualTileSize)
 		dc.SetRGBA(0, 0, 0, 0.5)
@@ -67,47 +66,57 @@ func init() {
 		dc.Stroke()
 		dotImage = ebiten.NewImageFromImage(dc.Image())
 	}
+
+	stairsImages[0] = ebiten.NewImageFromImage(makeStairsImage(false))
+	stairsImages[1] = ebiten.NewImageFromImage(makeStairsImage(true))
 }
 
 // Tile object describes a tile
 type Tile struct {
 	// members that do not change until a new grid is created
 	X, Y           int
-	worldX, worldY float64 // position of tile
-	edges          [4]*Tile
-	pen            bool // true if this tile is part of the central pen
+	worldX, worldY float64   // position of top left of the TileSize box centered on the tile, set by Topology.Link
+	edges          []*Tile   // neighbouring tiles, indexed by Direction; nil if there is no neighbour that way
+	pen            bool      // true if this tile is part of the central pen
+	floor          int       // which floor of the level this tile is on, 0 being the one with the pen
+	stairs         *Tile     // the tile at the other end of the stairs on this tile, nil if there aren't any
+	under          *Tile     // the tunnel under this tile, if it's a crossing in a weave maze
+	over           *Tile     // the crossing above this tile, if it's a tunnel
+	terrain        Terrain   // what the floor of this tile is made of
+	conveyor       Direction // the way a CONVEYOR pushes
 
 	// members that may change
 	walls uint // bit mask of the walls this tile currently has
 
 	// volatile members
-	marked  bool  // true if this tile is part of marked path for puck to follow
-	visited bool  // temporarily used when doing BFS of grid
-	parent  *Tile // temporarily used to find puck's marked path
+	marked    uint // bit field of the pucks (Puck.mark) whose marked paths go through this tile
+	visited   bool // true if this tile can be reached from the pen (set by visitTiles); used as scratch space by generators
+	component int  // which group of joined-up tiles this one is in, kept up to date as walls change
 }
 
 // NewTile creates a new Tile object and returns a pointer to it
-// all new tiles start with all four walls before they are carved later
+// all new tiles start with all their walls before they are carved later
 func NewTile(x, y int) *Tile {
-	t := &Tile{X: x, Y: y, walls: ALL_WALLS}
-	// worldX, worldY will be (re)set by Layout()
+	t := &Tile{X: x, Y: y, edges: make([]*Tile, TheTopology.Directions())}
+	t.walls = t.allWalls()
+	// worldX, worldY will be set by Topology.Link()
 	return t
 }
 
+// allWalls returns the bit mask of a tile with every wall present
+func (t *Tile) allWalls() uint {
+	return 1<<len(t.edges) - 1
+}
+
 // reset prepares a Tile for a new level by resetting just gameplay data, not structural data
 // func (t *Tile) reset() {
 // 	t.walls = ALL_WALLS
 // 	t.visited = false
-// 	t.parent = nil
 // }
 
-// rect gives the x,y screen coords of the tile's top left and bottom right corners
-func (t *Tile) rect() (x0 int, y0 int, x1 int, y1 int) {
-	x0 = t.X * TileSize
-	y0 = t.Y * TileSize
-	x1 = x0 + TileSize
-	y1 = y0 + TileSize
-	return // using named return parameters
+// contains returns true if pt (in world coords) is on this tile
+func (t *Tile) contains(pt image.Point) bool {
+	return util.PointInPolygon(pt, TheTopology.Outline(t))
 }
 
 // neighbour returns the neighbouring tile in that direction
@@ -117,26 +126,23 @@ func (t *Tile) neighbour(d Direction) *Tile {
 
 // isWall returns true if there is a wall in that direction
 func (t *Tile) isWall(d Direction) bool {
-	bit := wallbits[d]
+	bit := uint(1) << d
 	return t.walls&bit == bit
 }
 
 func (t *Tile) addWall(d Direction) {
-	t.walls |= wallbits[d]
+	t.walls |= 1 << d
 	if tn := t.neighbour(d); tn != nil {
-		tn.walls |= wallopps[d]
+		tn.walls |= 1 << whichDirIs(tn, t)
 	}
 }
 
 func (t *Tile) removeWall(d Direction) {
 	if tn := t.neighbour(d); tn != nil {
-		var mask uint
 		// unset the wall bit on this tile
-		mask = ALL_WALLS & ^wallbits[d]
-		t.walls &= mask
+		t.walls &^= 1 << d
 		// unset the opposite wall bit on the neighbour tile
-		mask = ALL_WALLS & ^wallopps[d]
-		tn.walls &= mask
+		tn.walls &^= 1 << whichDirIs(tn, t)
 	}
 }
 
@@ -156,64 +162,23 @@ func (t *Tile) removeAllWalls() {
 	}
 }
 
-// func (t *Tile) wallCount() int {
-// 	return bits.OnesCount(t.walls)
-// }
-
-// func (t *Tile) fillCulDeSac() {
-// 	if t.wallCount() == 3 {
-// 		for d := 0; d < 4; d++ {
-// 			if t.walls&wallbits[d] == 0 {
-// 				t.walls = MASK
-// 				if tn := t.Neighbour(d); tn != nil {
-// 					tn.walls |= wallopps[d]
-// 				}
-// 				break
-// 			}
-// 		}
-// 	}
-// }
-
-func (t *Tile) recursiveBacktracker() {
-	dirs := [4]Direction{0, 1, 2, 3}
-	rand.Shuffle(len(dirs), func(i, j int) { dirs[i], dirs[j] = dirs[j], dirs[i] })
-	// dirs := rand.Perm(4)
-	for d := 0; d < 4; d++ {
-		dir := dirs[d]
-		tn := t.neighbour(dir)
-		if tn != nil && !tn.visited {
-			t.removeWall(dir)
-			tn.visited = true
-			tn.recursiveBacktracker()
-		}
-	}
+// wallCount returns the number of walls this tile has, including those on the edge of the grid
+func (t *Tile) wallCount() int {
+	return bits.OnesCount(t.walls)
 }
 
-// mark this tile as 'in'
-// and then adds marked/in neighbours to the frontier tiles
-func (t *Tile) primMark(pfrontier *[]*Tile) {
-	t.visited = true
-	for _, dir := range ALL_DIRECTIONS {
-		n := t.edges[dir]
-		if n != nil && !n.visited {
-			*pfrontier = append(*pfrontier, n)
-		}
-	}
+// exits returns the number of ways out of this tile
+func (t *Tile) exits() int {
+	return len(t.edges) - t.wallCount()
 }
 
-// return all the 'in' neighbours
-func (t *Tile) primNeighbours() []*Tile {
-	var lst []*Tile = []*Tile{}
-	for _, dir := range ALL_DIRECTIONS {
-		n := t.edges[dir]
-		if n != nil && n.visited {
-			lst = append(lst, n)
-		}
-	}
-	return lst
+// isCulDeSac returns true if this tile is a dead end, with only one way out
+func (t *Tile) isCulDeSac() bool {
+	return t.exits() == 1
 }
 
-func primWhichDirIs(src, dst *Tile) Direction {
+// whichDirIs returns the direction from src to its neighbour dst, or -1 if they are not neighbours
+func whichDirIs(src, dst *Tile) Direction {
 	for _, dir := range ALL_DIRECTIONS {
 		if src.edges[dir] == dst {
 			return dir
@@ -222,68 +187,77 @@ func primWhichDirIs(src, dst *Tile) Direction {
 	return -1
 }
 
-func (t *Tile) prim() {
-	if t.visited {
-		log.Fatal("Tile.visited is true")
-	}
-	var frontier []*Tile = []*Tile{}
-	t.primMark(&frontier)
-	for len(frontier) > 0 {
-		// remove a random frontier tile
-
-		// we do not care about ordering,
-		// so replace the element to delete with the one at the end of the slice
-		// and then return the first len-1 elements
-		i := rand.Intn(len(frontier))
-		t1 := frontier[i]
-		frontier[i] = frontier[len(frontier)-1]
-		frontier = frontier[:len(frontier)-1]
-		lst := t1.primNeighbours()
-		t2 := lst[rand.Intn(len(lst))]
-		if (t1.visited && !t2.visited) || (!t1.visited && t2.visited) {
-			// remove wall between t1 and t2
-			dir := primWhichDirIs(t1, t2)
-			if dir == -1 {
-				log.Fatal("dir is -1")
-			}
-			t1.removeWall(dir)
-		}
-		// mark the frontier tile as being 'in' the maze
-		// (and add any of it's outside neighbours to the frontier)
-		t1.primMark(&frontier)
-	}
+// position of this tile (top left origin of a TileSize box centered on it) in world coords
+func (t *Tile) position() (float64, float64) {
+	return t.worldX, t.worldY
 }
 
-// position of this tile (top left origin) in screen coords
-func (t *Tile) position() (float64, float64) {
-	// Tile.Layout() may not have been called yet
-	return float64(t.X * TileSize), float64(t.Y * TileSize)
-	// return t.worldX, t.worldY
+// center of this tile in world coords
+func (t *Tile) center() (float64, float64) {
+	return t.worldX + TileSize/2, t.worldY + TileSize/2
+}
+
+// positionNear returns the position of t, or on a wrapped grid the position of
+// whichever copy of t is nearest to x,y, so lerping across a seam goes the short way
+func (t *Tile) positionNear(x, y float64) (float64, float64) {
+	tx, ty := t.position()
+	return wrapNear(tx, x, wrapWidth), wrapNear(ty, y, wrapHeight)
 }
 
-// whichQuadrant - given a point (in screen/world coords) that is assumed to be on this tile,
-// return which direction/quadrant (0,1,2,3) the point is within. Imagine a diagonal cross
-// on the tile. TODO this looks fugly.
-func (t *Tile) whichQuadrant(pt image.Point) Direction {
-	topLeft := image.Point{X: int(t.worldX), Y: int(t.worldY)}
-	topRight := image.Point{X: int(t.worldX) + TileSize, Y: int(t.worldY)}
-	center := image.Point{X: int(t.worldX) + (TileSize / 2), Y: int(t.worldY) + (TileSize / 2)}
-	bottomLeft := image.Point{X: int(t.worldX), Y: int(t.worldY) + TileSize}
-	bottomRight := image.Point{X: int(t.worldX) + TileSize, Y: int(t.worldY) + TileSize}
-	if util.PointInTriangle(pt, topLeft, topRight, center) {
-		return NORTH
+// wrapNear moves v by whole periods until it's as close as it can be to near; a period of 0 means no wrapping
+func wrapNear(v, near, period float64) float64 {
+	if period == 0 {
+		return v
 	}
-	if util.PointInTriangle(pt, topRight, bottomRight, center) {
-		return EAST
+	for v-near > period/2 {
+		v -= period
 	}
-	if util.PointInTriangle(pt, bottomLeft, bottomRight, center) {
-		return SOUTH
+	for near-v > period/2 {
+		v += period
+	}
+	return v
+}
+
+// wrapInside moves v by whole periods until it's between 0 and period; a period of 0 means no wrapping
+func wrapInside(v, period float64) float64 {
+	if period == 0 {
+		return v
 	}
-	if util.PointInTriangle(pt, topLeft, bottomLeft, center) {
-		return WEST
+	v = math.Mod(v, period)
+	if v < 0 {
+		v += period
 	}
+	return v
+}
 
-	return -1
+// wallTowards returns the direction of the wall nearest to the angle (radians clockwise from east)
+func (t *Tile) wallTowards(angle float64) Direction {
+	best, bestDiff := Direction(-1), math.Inf(1)
+	for _, d := range ALL_DIRECTIONS {
+		if diff := math.Abs(angleBetween(angle, TheTopology.WallAngle(t, d))); diff < bestDiff {
+			best, bestDiff = d, diff
+		}
+	}
+	return best
+}
+
+// whichWall - given a point (in world coords) that is assumed to be on this tile,
+// return the direction of the wall the point is nearest to. On square tiles,
+// imagine a diagonal cross on the tile.
+func (t *Tile) whichWall(pt image.Point) Direction {
+	cx, cy := t.center()
+	return t.wallTowards(math.Atan2(float64(pt.Y)-cy, float64(pt.X)-cx))
+}
+
+// angleBetween returns the signed difference b - a, normalized to the range -Pi .. Pi
+func angleBetween(a, b float64) float64 {
+	d := math.Mod(b-a, 2*math.Pi)
+	if d > math.Pi {
+		d -= 2 * math.Pi
+	} else if d <= -math.Pi {
+		d += 2 * math.Pi
+	}
+	return d
 }
 
 // String representation of this tile
@@ -291,12 +265,6 @@ func (t *Tile) String() string {
 	return fmt.Sprintf("[%v,%v]", t.X, t.Y)
 }
 
-// Layout the tile
-func (t *Tile) Layout() {
-	t.worldX = float64(t.X * TileSize)
-	t.worldY = float64(t.Y * TileSize)
-}
-
 // Update the tile state (transitions, user input)
 func (t *Tile) Update() error {
 	return nil
@@ -315,6 +283,12 @@ func (t *Tile) Update() error {
 // 	text.Draw(screen, str, TheAcmeFonts.large, tx, ty, c)
 // }
 
+// centerImage translates op so that img will be drawn centered on this tile
+func (t *Tile) centerImage(op *ebiten.DrawImageOptions, img *ebiten.Image) {
+	cx, cy := t.center()
+	op.GeoM.Translate(cx-float64(img.Bounds().Dx()/2), cy-float64(img.Bounds().Dy()/2))
+}
+
 // Draw renders a Tile object
 func (t *Tile) Draw(screen *ebiten.Image) {
 
@@ -338,9 +312,11 @@ func (t *Tile) Draw(screen *ebiten.Image) {
 
 	// scale, point translation, rotate, object translation
 
+	img := TheTopology.TileImage(t)
+
 	op := &ebiten.DrawImageOptions{}
 
-	op.GeoM.Translate(t.worldX-overSize, t.worldY-overSize)
+	t.centerImage(op, img)
 	op.GeoM.Translate(CameraX, CameraY)
 
 	// Reset RGB (not Alpha) forcibly
@@ -355,11 +331,7 @@ func (t *Tile) Draw(screen *ebiten.Image) {
 		// op.ColorScale.Scale(r, g, b, 1)
 	}
 
-	if t.visited {
-		screen.DrawImage(reachableImages[t.walls], op)
-	} else {
-		screen.DrawImage(unreachableImages[t.walls], op)
-	}
+	screen.DrawImage(img, op)
 
 	// if DebugMode {
 	// ebitenutil.DrawLine is really slow
@@ -383,14 +355,32 @@ func (t *Tile) Draw(screen *ebiten.Image) {
 	// t.debugText(gridImage, fmt.Sprintf("%04b", t.walls))
 }
 
+// DrawStairs shows which way the stairs on this tile go, if it has any
+func (t *Tile) DrawStairs(screen *ebiten.Image) {
+	if t.stairs != nil {
+		img := stairsImages[0]
+		if t.stairs.floor > t.floor {
+			img = stairsImages[1]
+		}
+		op := &ebiten.DrawImageOptions{}
+		t.centerImage(op, img)
+		op.GeoM.Translate(CameraX, CameraY)
+		screen.DrawImage(img, op)
+	}
+}
+
 // DrawMarked renders a mark on a Tile object
 func (t *Tile) DrawMarked(screen *ebiten.Image) {
 	// https://ebiten.org/documents/performancetips.html
 	// batch drawing of similar objects: don't intermingle drawing of tileImage and dotImage objects
-	if t.marked {
-		op := &ebiten.DrawImageOptions{}
-		op.GeoM.Translate(t.worldX-overSize, t.worldY-overSize)
-		op.GeoM.Translate(CameraX, CameraY)
-		screen.DrawImage(dotImage, op)
+	// each puck's path is dotted in the puck's colour
+	for _, p := range TheGrid.pucks {
+		if p.isMarked(t) {
+			op := &ebiten.DrawImageOptions{}
+			t.centerImage(op, dotImage)
+			op.GeoM.Translate(CameraX, CameraY)
+			op.ColorM.Translate(float64(p.col.R)/0xff, float64(p.col.G)/0xff, float64(p.col.B)/0xff, 0)
+			screen.DrawImage(dotImage, op)
+		}
 	}
 }
@@ -0,0 +1,152 @@
+package maze
+
+import (
+	"image"
+	"math"
+
+	"github.com/fogleman/gg"
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// Topology describes the shape of a grid's tiles and how they fit together.
+// Tile.walls is a bit mask with bit d set if there is a wall in direction d.
+// On square and hex tiles, directions are numbered in the same order as the corners
+// returned by Outline, so wall d is the edge between corner d and corner d+1.
+type Topology interface {
+	// Directions returns how many walls (and so potential neighbours) each tile has
+	Directions() int
+	// Link creates the tiles of a TilesAcross x TilesDown grid (or whatever
+	// shape the topology makes from them), sets their world positions,
+	// fills in Grid.rows, and links each tile to its neighbours
+	Link(g *Grid)
+	// Pen returns the tiles that form the central pen; the first one is where the puck starts
+	Pen(g *Grid) []*Tile
+	// WallAngle returns the direction of wall d from the middle of t, in radians clockwise from east
+	WallAngle(t *Tile, d Direction) float64
+	// Outline returns the corners of t in world coords, with curved edges
+	// broken into short straight ones
+	Outline(t *Tile) []image.Point
+	// TileImage returns the image to draw (centered on the tile) for t as it is now
+	TileImage(t *Tile) *ebiten.Image
+	// Size returns the size of the world in pixels
+	Size() (int, int)
+}
+
+// minimapOutliner is implemented by topologies whose world isn't a rectangle,
+// so the minimap can draw the right shape around itself
+type minimapOutliner interface {
+	drawMinimapOutline(dc *gg.Context, w, h float64)
+}
+
+// TheTopology is the shape of the grid currently being played, set by NewGrid;
+// like TilesAcross and TilesDown, it's a package-level variable so it can be seen by Tile
+var TheTopology Topology = SquareTopology{}
+
+// setTopology makes topo the current topology, defaulting to square tiles
+func setTopology(topo Topology) {
+	if topo == nil {
+		topo = SquareTopology{}
+	}
+	TheTopology = topo
+	ALL_DIRECTIONS = make([]Direction, topo.Directions())
+	for d := range ALL_DIRECTIONS {
+		ALL_DIRECTIONS[d] = Direction(d)
+	}
+}
+
+// addRows creates a rectangular TilesAcross x TilesDown grid of tiles,
+// calling place on each so the topology can set its world position
+func (g *Grid) addRows(place func(*Tile)) {
+	g.tiles = make([]*Tile, 0, TilesAcross*TilesDown)
+	g.rows = make([][]*Tile, TilesDown)
+	for y := range g.rows {
+		g.rows[y] = make([]*Tile, TilesAcross)
+		for x := range g.rows[y] {
+			t := NewTile(x, y)
+			place(t)
+			g.rows[y][x] = t
+			g.tiles = append(g.tiles, t)
+		}
+	}
+}
+
+// SquareTopology is the original grid of square tiles, each with a north, east, south and west wall
+type SquareTopology struct{}
+
+// Directions implements Topology
+func (SquareTopology) Directions() int {
+	return 4
+}
+
+// Link implements Topology
+func (SquareTopology) Link(g *Grid) {
+	g.addRows(func(t *Tile) {
+		t.worldX = float64(t.X * TileSize)
+		t.worldY = float64(t.Y * TileSize)
+	})
+
+	// link the tiles together to avoid all that tedious 2d array stuff
+	for _, t := range g.tiles {
+		x := t.X
+		y := t.Y
+		t.edges[NORTH] = g.findTile(x, y-1)
+		t.edges[EAST] = g.findTile(x+1, y)
+		t.edges[SOUTH] = g.findTile(x, y+1)
+		t.edges[WEST] = g.findTile(x-1, y)
+	}
+}
+
+// Pen implements Topology; the pen is a 3x3 square of tiles in the middle of the grid
+func (SquareTopology) Pen(g *Grid) []*Tile {
+	midX := TilesAcross / 2
+	midY := TilesDown / 2
+	pen := []*Tile{g.findTile(midX, midY)}
+	for x := midX - 1; x <= midX+1; x++ {
+		for y := midY - 1; y <= midY+1; y++ {
+			if x != midX || y != midY {
+				pen = append(pen, g.findTile(x, y))
+			}
+		}
+	}
+	return pen
+}
+
+// WallAngle implements Topology
+func (SquareTopology) WallAngle(t *Tile, d Direction) float64 {
+	return float64(d-1) * math.Pi / 2
+}
+
+// Outline implements Topology
+func (SquareTopology) Outline(t *Tile) []image.Point {
+	x0, y0 := int(t.worldX), int(t.worldY)
+	x1, y1 := x0+TileSize, y0+TileSize
+	return []image.Point{{X: x0, Y: y0}, {X: x1, Y: y0}, {X: x1, Y: y1}, {X: x0, Y: y1}}
+}
+
+// TileImage implements Topology
+func (SquareTopology) TileImage(t *Tile) *ebiten.Image {
+	if t.under != nil {
+		key := t.walls
+		if t.tunnelEastWest() {
+			key |= 1 << 4
+		}
+		if !t.visited {
+			key |= 1 << 5
+		}
+		img, ok := crossingImages[key]
+		if !ok {
+			img = ebiten.NewImageFromImage(makeCrossingTileImage(t.walls, t.tunnelEastWest(), !t.visited))
+			crossingImages[key] = img
+		}
+		return img
+	}
+	if t.visited {
+		return reachableImages[t.walls]
+	}
+	return unreachableImages[t.walls]
+}
+
+// Size implements Topology
+func (SquareTopology) Size() (int, int) {
+	return TilesAcross * TileSize, TilesDown * TileSize
+}
@@ -6,6 +6,19 @@ type UserData struct {
 	Copyright       string
 	Game            string
 	CompletedLevels int
+	BestScores      []int // indexed by level; zero if a level hasn't been scored yet
+	UnlockedLevels  int   // number of levels that can be chosen from the level select menu, whatever CompletedLevels is
+}
+
+// unlocked returns true if level can be chosen from the level select menu;
+// the first level, and any up to the one being played, always can
+func (ud *UserData) unlocked(level int) bool {
+	return level <= ud.CompletedLevels || level < ud.UnlockedLevels
+}
+
+// unlock lets level be chosen from the level select menu from now on
+func (ud *UserData) unlock(level int) {
+	ud.UnlockedLevels = max(ud.UnlockedLevels, level+1)
 }
 
 // UserDataIO performsLoad/Save of UserData objects
@@ -47,23 +47,12 @@ func (ud *UserData) Load() {
 	if err != nil {
 		return
 	}
-	file, err := os.Open(pathname)
-	if err == nil && file != nil {
-		defer file.Close()
-
-		bytes := make([]byte, 256)
-		var count int
-		count, err = file.Read(bytes)
+	bytes, err := os.ReadFile(pathname)
+	if err == nil && len(bytes) > 0 {
+		err = json.Unmarshal(bytes, ud)
 		if err != nil {
 			log.Fatal(err)
 		}
-		if count > 0 {
-			// golang gotcha reslice buffer to number of bytes actually read
-			err = json.Unmarshal(bytes[:count], ud)
-			if err != nil {
-				log.Fatal(err)
-			}
-		}
 	}
 }
 
@@ -0,0 +1,57 @@
+package maze
+
+// A crossing is a square tile with a straight passage over the top, and a tunnel
+// underneath at right angles to it. The tunnel is a Tile of its own, hanging off the
+// crossing's under field; it isn't in Grid.tiles, so it can't be tapped or drawn,
+// but the tiles either side of the crossing are linked to it instead of to the crossing,
+// so anything going through the tunnel can't turn off into the passage above.
+
+// canCross returns true if t could become a crossing: it hasn't been carved yet, it has no stairs,
+// and it has four neighbours, none of which are crossings or tunnels themselves
+func (t *Tile) canCross() bool {
+	if t.pen || t.stairs != nil || t.under != nil || t.over != nil || len(t.edges) != 4 || t.walls != t.allWalls() {
+		return false
+	}
+	for _, tn := range t.edges {
+		if tn == nil || tn.under != nil || tn.over != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// addTunnel makes t a crossing, with a tunnel running under it in direction d (and the opposite way),
+// joining the neighbours on either side; it returns the tunnel
+func (t *Tile) addTunnel(d Direction) *Tile {
+	u := NewTile(t.X, t.Y)
+	u.worldX, u.worldY = t.worldX, t.worldY
+	u.floor = t.floor
+	u.over = t
+	t.under = u
+	for _, dir := range []Direction{d, (d + 2) % 4} {
+		tn := t.edges[dir]
+		tn.edges[whichDirIs(tn, t)] = u
+		u.edges[dir] = tn
+		t.edges[dir] = nil
+		t.walls |= 1 << dir
+		u.removeWall(dir)
+	}
+	return u
+}
+
+// removeTunnel turns a crossing back into an ordinary tile, relinking it to the neighbours the tunnel had
+func (t *Tile) removeTunnel() {
+	u := t.under
+	for d, tn := range u.edges {
+		if tn != nil {
+			tn.edges[whichDirIs(tn, u)] = t
+			t.edges[d] = tn
+		}
+	}
+	t.under = nil
+}
+
+// tunnelEastWest returns true if the tunnel under a crossing runs east to west
+func (t *Tile) tunnelEastWest() bool {
+	return t.under.edges[EAST] != nil
+}
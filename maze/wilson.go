@@ -0,0 +1,40 @@
+package maze
+
+import (
+	"math/rand"
+)
+
+// Wilson carves a maze using loop-erased random walks, which produces
+// a uniform spanning tree: every possible maze is equally likely
+type Wilson struct{}
+
+// Generate implements Generator
+func (Wilson) Generate(g *Grid, rng *rand.Rand) {
+	order := rng.Perm(len(g.tiles))
+
+	g.tiles[order[0]].visited = true
+
+	anyTile := func(*Tile) bool { return true }
+
+	for _, i := range order[1:] {
+		start := g.tiles[i]
+		if start.visited {
+			continue
+		}
+		// random walk until we hit the maze, remembering the last way out of each tile;
+		// overwriting the way out when a tile is revisited erases the loop
+		exits := map[*Tile]Direction{}
+		for t := start; !t.visited; {
+			d := randomNeighbour(t, rng, anyTile)
+			exits[t] = d
+			t = t.neighbour(d)
+		}
+		// carve the loop-erased path into the maze
+		for t := start; !t.visited; {
+			d := exits[t]
+			t.removeWall(d)
+			t.visited = true
+			t = t.neighbour(d)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// Package metrics measures the shape of a carved maze,
+// so that levels can be tuned and unsuitable mazes thrown away
+package metrics
+
+import (
+	"fmt"
+)
+
+// Graph is all that metrics needs to know about a maze;
+// cells are numbered 0 .. Len()-1
+type Graph interface {
+	Len() int
+	// Exits returns the cells that can be reached in one step from cell i
+	Exits(i int) []int
+}
+
+// Report holds the measurements of a maze
+type Report struct {
+	Cells       int     // total number of cells
+	Unreachable int     // cells that can't be reached from the sources
+	DeadEnds    int     // reachable cells with only one way out
+	Junctions   int     // reachable cells with three or more ways out
+	Diameter    int     // longest shortest path between any two reachable cells
+	AvgCorridor float64 // average length of runs of cells with exactly two ways out
+	River       float64 // fraction of reachable cells that are corridor (exactly two ways out)
+	Distances   []int   // Distances[n] is the number of cells n steps from the nearest source
+	AvgDistance float64 // average distance of reachable cells from the nearest source
+}
+
+// bfs returns the number of steps from the sources to every cell, -1 meaning unreachable
+func bfs(g Graph, sources []int) []int {
+	dist := make([]int, g.Len())
+	for i := range dist {
+		dist[i] = -1
+	}
+	q := make([]int, 0, g.Len())
+	for _, s := range sources {
+		if dist[s] == -1 {
+			dist[s] = 0
+			q = append(q, s)
+		}
+	}
+	for len(q) > 0 {
+		i := q[0]
+		q = q[1:] // take first cell off front of queue
+		for _, j := range g.Exits(i) {
+			if dist[j] == -1 {
+				dist[j] = dist[i] + 1
+				q = append(q, j)
+			}
+		}
+	}
+	return dist
+}
+
+// Analyse measures a maze, with distances measured from the source cells (usually the pen)
+func Analyse(g Graph, sources []int) Report {
+	n := g.Len()
+	r := Report{Cells: n}
+
+	degree := make([]int, n)
+	for i := 0; i < n; i++ {
+		degree[i] = len(g.Exits(i))
+	}
+
+	dist := bfs(g, sources)
+	var reachable []int
+	total := 0
+	for i, d := range dist {
+		if d == -1 {
+			r.Unreachable++
+			continue
+		}
+		reachable = append(reachable, i)
+		for len(r.Distances) <= d {
+			r.Distances = append(r.Distances, 0)
+		}
+		r.Distances[d]++
+		total += d
+		switch {
+		case degree[i] == 1:
+			r.DeadEnds++
+		case degree[i] >= 3:
+			r.Junctions++
+		}
+	}
+	if len(reachable) == 0 {
+		return r
+	}
+	r.AvgDistance = float64(total) / float64(len(reachable))
+
+	// the maze may have loops, so the two-sweep trick for trees isn't good enough
+	for _, i := range reachable {
+		for _, d := range bfs(g, []int{i}) {
+			if d > r.Diameter {
+				r.Diameter = d
+			}
+		}
+	}
+
+	// flood fill each run of corridor cells to find its length
+	seen := make([]bool, n)
+	corridors, corridorCells := 0, 0
+	for _, i := range reachable {
+		if degree[i] != 2 || seen[i] {
+			continue
+		}
+		corridors++
+		stack := []int{i}
+		seen[i] = true
+		for len(stack) > 0 {
+			c := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			corridorCells++
+			for _, j := range g.Exits(c) {
+				if degree[j] == 2 && !seen[j] {
+					seen[j] = true
+					stack = append(stack, j)
+				}
+			}
+		}
+	}
+	if corridors > 0 {
+		r.AvgCorridor = float64(corridorCells) / float64(corridors)
+	}
+	r.River = float64(corridorCells) / float64(len(reachable))
+
+	return r
+}
+
+// Difficulty boils the report down to a single number; bigger is harder to herd.
+// It is the average distance a kitten has to be herded to reach the pen,
+// scaled up by the proportion of dead ends it can hide in.
+func (r Report) Difficulty() float64 {
+	reachable := r.Cells - r.Unreachable
+	if reachable == 0 {
+		return 0
+	}
+	return r.AvgDistance * (1 + float64(r.DeadEnds)/float64(reachable))
+}
+
+// String representation of the report
+func (r Report) String() string {
+	return fmt.Sprintf("cells %d unreachable %d deadends %d junctions %d diameter %d corridor %.2f river %.2f distance %.2f difficulty %.2f",
+		r.Cells, r.Unreachable, r.DeadEnds, r.Junctions, r.Diameter, r.AvgCorridor, r.River, r.AvgDistance, r.Difficulty())
+}
+
+// Band is a range of acceptable difficulty; the zero Band accepts anything
+type Band struct {
+	Min, Max float64
+}
+
+// Contains returns true if the report's difficulty falls within the band
+func (b Band) Contains(r Report) bool {
+	if b.Min == 0 && b.Max == 0 {
+		return true
+	}
+	d := r.Difficulty()
+	return d >= b.Min && d <= b.Max
+}
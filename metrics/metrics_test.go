@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+// adjacency is a Graph given as a list of exits for each cell
+type adjacency [][]int
+
+func (a adjacency) Len() int          { return len(a) }
+func (a adjacency) Exits(i int) []int { return a[i] }
+
+// a corridor 0-1-2-3, a loop 3-4-5-6-3, and 7 on its own
+var tiny = adjacency{
+	{1},       // 0 dead end, the source
+	{0, 2},    // 1
+	{1, 3},    // 2
+	{2, 4, 6}, // 3 junction
+	{3, 5},    // 4
+	{4, 6},    // 5 furthest from the source
+	{5, 3},    // 6
+	{},        // 7 can't be reached
+}
+
+func TestAnalyse(t *testing.T) {
+	want := Report{
+		Cells:       8,
+		Unreachable: 1,
+		DeadEnds:    1,
+		Junctions:   1,
+		Diameter:    5,
+		AvgCorridor: 5.0 / 2, // runs 1-2 and 4-5-6
+		River:       5.0 / 7,
+		Distances:   []int{1, 1, 1, 1, 2, 1},
+		AvgDistance: 19.0 / 7,
+	}
+	got := Analyse(tiny, []int{0})
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got  %+v\nwant %+v", got, want)
+	}
+	if d := got.Difficulty(); d != 19.0/7*(1+1.0/7) {
+		t.Errorf("difficulty %v", d)
+	}
+}
+
+func TestAnalyseNothingReachable(t *testing.T) {
+	got := Analyse(adjacency{{}, {}}, nil)
+	want := Report{Cells: 2, Unreachable: 2}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got  %+v\nwant %+v", got, want)
+	}
+	if d := got.Difficulty(); d != 0 {
+		t.Errorf("difficulty %v", d)
+	}
+}
+
+func TestBandContains(t *testing.T) {
+	easy := Report{}
+	hard := Analyse(tiny, []int{0})
+	for _, tc := range []struct {
+		band Band
+		r    Report
+		want bool
+	}{
+		{Band{}, easy, true},
+		{Band{}, hard, true},
+		{Band{}, Report{Cells: 1, AvgDistance: 1e9, DeadEnds: 1}, true},
+		{Band{Min: 1, Max: 10}, easy, false},
+		{Band{Min: 1, Max: 10}, hard, true},
+		{Band{Min: 4, Max: 10}, hard, false},
+		{Band{Min: 0, Max: 2}, hard, false},
+	} {
+		if got := tc.band.Contains(tc.r); got != tc.want {
+			t.Errorf("%+v contains difficulty %v is %v, want %v", tc.band, tc.r.Difficulty(), got, tc.want)
+		}
+	}
+}
@@ -81,3 +81,17 @@ func PointInTriangle(pt, v1, v2, v3 image.Point) bool {
 	has_pos := (d1 > 0) || (d2 > 0) || (d3 > 0)
 	return !(has_neg && has_pos)
 }
+
+// PointInPolygon returns true if pt is inside the polygon with corners poly, using the even-odd rule
+// https://wrfranklin.org/Research/Short_Notes/pnpoly.html
+func PointInPolygon(pt image.Point, poly []image.Point) bool {
+	inside := false
+	for i, j := 0, len(poly)-1; i < len(poly); j, i = i, i+1 {
+		pi, pj := poly[i], poly[j]
+		if (pi.Y > pt.Y) != (pj.Y > pt.Y) &&
+			float64(pt.X) < float64(pj.X-pi.X)*float64(pt.Y-pi.Y)/float64(pj.Y-pi.Y)+float64(pi.X) {
+			inside = !inside
+		}
+	}
+	return inside
+}